@@ -0,0 +1,77 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DialPhase names the step of a dial through a Route.
+type DialPhase string
+
+const (
+	// DialPhaseResolve is the resolution of a node address, or of the
+	// target by a Router.
+	DialPhaseResolve DialPhase = "resolve"
+	// DialPhaseDial is the Transporter dial of the first node, or the
+	// direct dial of the target when the route has no nodes.
+	DialPhaseDial DialPhase = "dial"
+	// DialPhaseHandshake is the Transporter handshake with a node.
+	DialPhaseHandshake DialPhase = "handshake"
+	// DialPhaseConnect is the connect through the previous node to a node,
+	// or through the last node to the target.
+	DialPhaseConnect DialPhase = "connect"
+	// DialPhaseBind is the reverse bind through the last node, or the
+	// direct listen when the route has no nodes.
+	DialPhaseBind DialPhase = "bind"
+)
+
+// DialError is the error returned by Route and Router dials and binds. It
+// identifies where the dial failed so that handlers can map it to protocol
+// status codes and metrics can be labelled per node. Use errors.As to
+// retrieve it from a returned error.
+type DialError struct {
+	// Node is the node at fault: the node being resolved, dialed,
+	// handshaken with or connected to, or, when connecting to the target
+	// or binding, the last node of the route. It is nil for direct dials
+	// and binds, and for the resolution of the target by a Router.
+	Node *Node
+	// Hop is the index of Node in the route.
+	Hop int
+	// Phase is the step that failed.
+	Phase DialPhase
+	// Attempt is the zero-based Router attempt that failed.
+	Attempt int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DialError) Error() string {
+	if e.Node == nil {
+		return fmt.Sprintf("%s: %v", e.Phase, e.Err)
+	}
+	return fmt.Sprintf("hop %d (%s@%s): %s: %v", e.Hop, e.Node.Name, e.Node.Addr, e.Phase, e.Err)
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports whether the failure was caused by a timeout.
+func (e *DialError) Timeout() bool {
+	if errors.Is(e.Err, context.DeadlineExceeded) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(e.Err, &ne) && ne.Timeout()
+}
+
+func newDialError(node *Node, hop int, phase DialPhase, err error) error {
+	return &DialError{
+		Node:  node,
+		Hop:   hop,
+		Phase: phase,
+		Err:   err,
+	}
+}
@@ -0,0 +1,139 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// HappyEyeballsMode selects how a Router dials a target whose name resolves
+// to several IP addresses (RFC 8305).
+type HappyEyeballsMode string
+
+const (
+	// HappyEyeballsPreferIPv6 tries the addresses with interleaved families,
+	// IPv6 first, starting the next attempt whenever the fallback delay
+	// elapses or the previous attempt fails.
+	HappyEyeballsPreferIPv6 HappyEyeballsMode = "prefer-ipv6"
+	// HappyEyeballsPreferIPv4 is like HappyEyeballsPreferIPv6 with IPv4 first.
+	HappyEyeballsPreferIPv4 HappyEyeballsMode = "prefer-ipv4"
+	// HappyEyeballsRace tries all addresses at once.
+	HappyEyeballsRace HappyEyeballsMode = "race"
+)
+
+// DefaultFallbackDelay is the delay between staggered connection attempts,
+// the "Connection Attempt Delay" recommended by RFC 8305.
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// sortIPs orders ips for a happy eyeballs dial: the preferred family first,
+// then alternating between families (RFC 8305 section 4).
+func sortIPs(ips []net.IP, mode HappyEyeballsMode) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+
+	first, second := v6, v4
+	if mode == HappyEyeballsPreferIPv4 {
+		first, second = v4, v6
+	}
+
+	sorted := make([]net.IP, 0, len(ips))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			sorted = append(sorted, first[i])
+		}
+		if i < len(second) {
+			sorted = append(sorted, second[i])
+		}
+	}
+	return sorted
+}
+
+// dialHappyEyeballs dials addrs in order with staggered starts and returns
+// the first connection established; the other attempts are canceled and
+// their connections closed. The next attempt starts when delay elapses or
+// an attempt fails. With race set, all attempts start at once. If every
+// attempt fails, the first error is returned.
+func dialHappyEyeballs(ctx context.Context, addrs []string, delay time.Duration, race bool,
+	dial func(ctx context.Context, addr string) (net.Conn, error)) (net.Conn, error) {
+	if delay <= 0 {
+		delay = DefaultFallbackDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+
+	next, pending := 0, 0
+	var timer *time.Timer
+	start := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			conn, err := dial(ctx, addr)
+			results <- result{conn: conn, err: err}
+		}()
+
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		if !race && next < len(addrs) {
+			timer = time.NewTimer(delay)
+		}
+	}
+
+	start()
+	for race && next < len(addrs) {
+		start()
+	}
+
+	var firstErr error
+	for pending > 0 {
+		var timeout <-chan time.Time
+		if timer != nil {
+			timeout = timer.C
+		}
+
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if timer != nil {
+					timer.Stop()
+				}
+				cancel()
+				go func(n int) {
+					for i := 0; i < n; i++ {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if next < len(addrs) && ctx.Err() == nil {
+				start()
+			}
+		case <-timeout:
+			timer = nil
+			start()
+		}
+	}
+
+	return nil, firstErr
+}
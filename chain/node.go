@@ -3,6 +3,7 @@ package chain
 import (
 	"context"
 	"regexp"
+	"sync"
 	"sync/atomic"
 
 	"github.com/go-gost/core/auth"
@@ -195,13 +196,41 @@ func PriorityNodeOption(priority int) NodeOption {
 type Node struct {
 	Name    string
 	Addr    string
-	marker  selector.Marker
+	state   atomic.Pointer[nodeState]
 	options NodeOptions
 
-	probeResult atomic.Value    // *ProbeResult
+	mu          sync.Mutex
 	probeCancel context.CancelFunc
 }
 
+// nodeState is the health state of a Node: its failure marker and latest
+// probe result. It may be shared by clones of the Node. A Node not created
+// by NewNode gets one on first use.
+type nodeState struct {
+	marker      atomic.Pointer[selector.Marker]
+	probeResult atomic.Value // *ProbeResult
+}
+
+func newNodeState() *nodeState {
+	s := &nodeState{}
+	m := selector.NewFailMarker()
+	s.marker.Store(&m)
+	return s
+}
+
+// CloneMode selects how a cloned Node relates to the health state of the
+// original.
+type CloneMode int
+
+const (
+	// CloneSharedState makes the clone share the failure marker and probe
+	// result with the original: marking either is seen by both.
+	CloneSharedState CloneMode = iota
+	// CloneFreshState gives the clone a new failure marker and no probe
+	// result.
+	CloneFreshState
+)
+
 // NewNode creates a new Node with the given name and address.
 func NewNode(name string, addr string, opts ...NodeOption) *Node {
 	var options NodeOptions
@@ -211,12 +240,22 @@ func NewNode(name string, addr string, opts ...NodeOption) *Node {
 		}
 	}
 
-	return &Node{
+	node := &Node{
 		Name:    name,
 		Addr:    addr,
-		marker:  selector.NewFailMarker(),
 		options: options,
 	}
+	node.state.Store(newNodeState())
+	return node
+}
+
+// loadState returns the health state of the Node, creating it if needed.
+func (node *Node) loadState() *nodeState {
+	if s := node.state.Load(); s != nil {
+		return s
+	}
+	node.state.CompareAndSwap(nil, newNodeState())
+	return node.state.Load()
 }
 
 // Options returns the Node's configuration.
@@ -231,19 +270,70 @@ func (node *Node) Metadata() metadata.Metadata {
 
 // Marker returns the Node's failure marker. Implements the selector.Markable interface.
 func (node *Node) Marker() selector.Marker {
-	return node.marker
+	if m := node.loadState().marker.Load(); m != nil {
+		return *m
+	}
+	return nil
 }
 
-// Copy returns a shallow copy of the Node.
+// SetMarker replaces the Node's failure marker, e.g. with one that feeds an
+// outlier detector. The marker is part of the health state, so it is also
+// replaced for clones sharing it. It is safe to call while the Node is in
+// use.
+func (node *Node) SetMarker(m selector.Marker) {
+	node.loadState().marker.Store(&m)
+}
+
+// Copy returns a copy of the Node sharing its health state, equivalent to
+// Clone(CloneSharedState).
 func (node *Node) Copy() *Node {
-	n := &Node{}
-	*n = *node
-	return n
+	return node.Clone(CloneSharedState)
+}
+
+// Clone returns a copy of the Node whose health state is either shared with
+// the Node or fresh, as selected by mode. The options are copied shallowly,
+// so the clone refers to the same Transporter, Bypass, Metadata and so on.
+// The clone never owns the Node's probe: closing the clone does not stop it.
+func (node *Node) Clone(mode CloneMode) *Node {
+	state := node.loadState()
+	if mode == CloneFreshState {
+		state = newNodeState()
+	}
+
+	clone := &Node{
+		Name:    node.Name,
+		Addr:    node.Addr,
+		options: node.options,
+	}
+	clone.state.Store(state)
+	return clone
+}
+
+// AdoptState makes the Node share the health state of from, e.g. when from
+// is replaced by the Node in a hop. If the Node has no probe running it also
+// takes over from's probe, so closing from no longer stops it. It is safe
+// to call while the Node is in use, e.g. by its probe.
+func (node *Node) AdoptState(from *Node) {
+	if from == nil || from == node {
+		return
+	}
+
+	node.state.Store(from.loadState())
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
+	if node.probeCancel == nil {
+		from.mu.Lock()
+		node.probeCancel = from.probeCancel
+		from.probeCancel = nil
+		from.mu.Unlock()
+	}
 }
 
 // ProbeResult implements chain.ProbeResultReader.
 func (node *Node) ProbeResult() *ProbeResult {
-	if v := node.probeResult.Load(); v != nil {
+	if v := node.loadState().probeResult.Load(); v != nil {
 		return v.(*ProbeResult)
 	}
 	return nil
@@ -251,18 +341,26 @@ func (node *Node) ProbeResult() *ProbeResult {
 
 // SetProbeResult stores the latest probe result.
 func (node *Node) SetProbeResult(r *ProbeResult) {
-	node.probeResult.Store(r)
+	node.loadState().probeResult.Store(r)
 }
 
 // SetProbeCancel stores the cancel function for the node's probe goroutine.
+// The probe is owned by this Node only, not by its clones.
 func (node *Node) SetProbeCancel(c context.CancelFunc) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+
 	node.probeCancel = c
 }
 
 // Close stops the node's probe goroutine if one is running.
 func (node *Node) Close() error {
-	if node.probeCancel != nil {
-		node.probeCancel()
+	node.mu.Lock()
+	cancel := node.probeCancel
+	node.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
 	}
 	return nil
 }
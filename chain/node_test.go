@@ -0,0 +1,158 @@
+package chain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-gost/core/selector"
+)
+
+func TestNodeZeroValue(t *testing.T) {
+	node := &Node{Name: "node", Addr: "127.0.0.1:8080"}
+
+	if node.Marker() == nil {
+		t.Fatal("Marker() = nil, want a marker")
+	}
+	if r := node.ProbeResult(); r != nil {
+		t.Fatalf("ProbeResult() = %v, want nil", r)
+	}
+
+	r := &ProbeResult{Latency: time.Second}
+	node.SetProbeResult(r)
+	if got := node.ProbeResult(); got != r {
+		t.Fatalf("ProbeResult() = %v, want %v", got, r)
+	}
+
+	m := selector.NewFailMarker()
+	node.SetMarker(m)
+	if got := node.Marker(); got != m {
+		t.Fatalf("Marker() = %v, want %v", got, m)
+	}
+}
+
+func TestNodeCopy(t *testing.T) {
+	node := NewNode("node", "127.0.0.1:8080", PriorityNodeOption(1))
+	c := node.Copy()
+
+	if c == node {
+		t.Fatal("Copy() returned the node itself")
+	}
+	if c.Name != node.Name || c.Addr != node.Addr || c.Options().Priority != 1 {
+		t.Fatalf("Copy() = %s@%s, want %s@%s", c.Name, c.Addr, node.Name, node.Addr)
+	}
+
+	node.Marker().Mark()
+	if n := c.Marker().Count(); n != 1 {
+		t.Fatalf("copy marker count = %d, want 1", n)
+	}
+}
+
+func TestNodeClone(t *testing.T) {
+	tests := []struct {
+		mode   CloneMode
+		shared bool
+	}{
+		{CloneSharedState, true},
+		{CloneFreshState, false},
+	}
+
+	for _, tt := range tests {
+		node := NewNode("node", "127.0.0.1:8080")
+		r := &ProbeResult{Latency: time.Second}
+		node.SetProbeResult(r)
+
+		c := node.Clone(tt.mode)
+		c.Marker().Mark()
+
+		if n := node.Marker().Count(); (n == 1) != tt.shared {
+			t.Errorf("mode %d: original marker count = %d, shared = %v", tt.mode, n, tt.shared)
+		}
+		if got := c.ProbeResult(); (got == r) != tt.shared {
+			t.Errorf("mode %d: clone probe result = %v, shared = %v", tt.mode, got, tt.shared)
+		}
+	}
+}
+
+func TestNodeClose(t *testing.T) {
+	node := NewNode("node", "127.0.0.1:8080")
+	ctx, cancel := context.WithCancel(context.Background())
+	node.SetProbeCancel(cancel)
+
+	c := node.Clone(CloneSharedState)
+	c.Close()
+	if ctx.Err() != nil {
+		t.Fatal("closing the clone stopped the probe of the original")
+	}
+
+	node.Close()
+	if ctx.Err() == nil {
+		t.Fatal("closing the original did not stop its probe")
+	}
+}
+
+func TestNodeAdoptState(t *testing.T) {
+	old := NewNode("node", "127.0.0.1:8080")
+	ctx, cancel := context.WithCancel(context.Background())
+	old.SetProbeCancel(cancel)
+	old.Marker().Mark()
+
+	node := NewNode("node", "127.0.0.1:8081")
+	node.AdoptState(old)
+
+	if n := node.Marker().Count(); n != 1 {
+		t.Fatalf("marker count = %d, want 1", n)
+	}
+
+	old.Close()
+	if ctx.Err() != nil {
+		t.Fatal("closing the replaced node stopped the adopted probe")
+	}
+	node.Close()
+	if ctx.Err() == nil {
+		t.Fatal("closing the node did not stop the adopted probe")
+	}
+}
+
+func TestNodeAdoptStateKeepsProbe(t *testing.T) {
+	old := NewNode("node", "127.0.0.1:8080")
+	oldCtx, oldCancel := context.WithCancel(context.Background())
+	old.SetProbeCancel(oldCancel)
+
+	node := NewNode("node", "127.0.0.1:8081")
+	ctx, cancel := context.WithCancel(context.Background())
+	node.SetProbeCancel(cancel)
+	node.AdoptState(old)
+
+	old.Close()
+	if oldCtx.Err() == nil {
+		t.Fatal("closing the replaced node did not stop its own probe")
+	}
+	if ctx.Err() != nil {
+		t.Fatal("closing the replaced node stopped the probe of the node")
+	}
+	node.Close()
+}
+
+func TestNodeAdoptStateConcurrent(t *testing.T) {
+	node := NewNode("node", "127.0.0.1:8080")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			node.SetProbeResult(&ProbeResult{Success: true})
+			node.Marker().Reset()
+			node.ProbeResult()
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		from := NewNode("node", "127.0.0.1:8080")
+		_, cancel := context.WithCancel(context.Background())
+		from.SetProbeCancel(cancel)
+		node.AdoptState(from)
+		from.Close()
+	}
+	<-done
+	node.Close()
+}
@@ -0,0 +1,417 @@
+package chain
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/selector"
+)
+
+const (
+	// DefaultOutlierConsecutiveErrors is the number of consecutive failures
+	// that ejects a node when no positive value is configured.
+	DefaultOutlierConsecutiveErrors = 5
+	// DefaultOutlierInterval is the default window over which error rate and
+	// latency are evaluated.
+	DefaultOutlierInterval = 10 * time.Second
+	// DefaultOutlierMinRequests is the default minimum number of outcomes in
+	// a window before its error rate and latency are evaluated.
+	DefaultOutlierMinRequests = 10
+	// DefaultOutlierBaseEjectionTime is the default duration of a first ejection.
+	DefaultOutlierBaseEjectionTime = 30 * time.Second
+	// DefaultOutlierMaxEjectionTime is the default cap on ejection duration.
+	DefaultOutlierMaxEjectionTime = 5 * time.Minute
+
+	// outlierLatencySamples caps the number of latency samples kept per window.
+	outlierLatencySamples = 256
+)
+
+// OutlierOptions holds the initialization parameters for an OutlierDetector.
+type OutlierOptions struct {
+	// ConsecutiveErrors is the number of consecutive failures that ejects a node.
+	ConsecutiveErrors int
+	// Interval is the window over which error rate and latency are evaluated.
+	Interval time.Duration
+	// MinRequests is the minimum number of outcomes in a window before its
+	// error rate and latency are evaluated.
+	MinRequests int
+	// ErrorRate ejects a node whose failure ratio in a window reaches this
+	// value (0 = disabled).
+	ErrorRate float64
+	// LatencyPercentile is the percentile (0-1) of latency compared
+	// against LatencyThreshold, e.g. 0.99.
+	LatencyPercentile float64
+	// LatencyThreshold ejects a node whose LatencyPercentile latency in a
+	// window exceeds this value (0 = disabled).
+	LatencyThreshold time.Duration
+	// BaseEjectionTime is the duration of a first ejection. Each further
+	// ejection doubles it.
+	BaseEjectionTime time.Duration
+	// MaxEjectionTime caps the duration of an ejection.
+	MaxEjectionTime time.Duration
+	// MaxEjectionPercent caps the percentage of attached nodes that may be
+	// ejected at the same time (0 = no cap).
+	MaxEjectionPercent int
+	// Logger is the logger for ejection events.
+	Logger logger.Logger
+}
+
+// OutlierOption is a functional option for configuring OutlierOptions.
+type OutlierOption func(opts *OutlierOptions)
+
+// ConsecutiveErrorsOutlierOption sets the consecutive failure threshold.
+func ConsecutiveErrorsOutlierOption(n int) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.ConsecutiveErrors = n
+	}
+}
+
+// IntervalOutlierOption sets the evaluation window.
+func IntervalOutlierOption(interval time.Duration) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.Interval = interval
+	}
+}
+
+// MinRequestsOutlierOption sets the minimum number of outcomes per window.
+func MinRequestsOutlierOption(n int) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.MinRequests = n
+	}
+}
+
+// ErrorRateOutlierOption sets the failure ratio threshold.
+func ErrorRateOutlierOption(rate float64) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.ErrorRate = rate
+	}
+}
+
+// LatencyOutlierOption sets the latency percentile and its threshold.
+func LatencyOutlierOption(percentile float64, threshold time.Duration) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.LatencyPercentile = percentile
+		opts.LatencyThreshold = threshold
+	}
+}
+
+// EjectionTimeOutlierOption sets the base and maximum ejection durations.
+func EjectionTimeOutlierOption(base, max time.Duration) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.BaseEjectionTime = base
+		opts.MaxEjectionTime = max
+	}
+}
+
+// MaxEjectionPercentOutlierOption sets the cap on simultaneously ejected nodes.
+func MaxEjectionPercentOutlierOption(percent int) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.MaxEjectionPercent = percent
+	}
+}
+
+// LoggerOutlierOption sets the logger.
+func LoggerOutlierOption(logger logger.Logger) OutlierOption {
+	return func(opts *OutlierOptions) {
+		opts.Logger = logger
+	}
+}
+
+// OutlierDetector passively detects misbehaving nodes from real traffic, in
+// the manner of Envoy's outlier detection. A node attached to the detector
+// gets a Marker that records the failures and successes reported by Route
+// (or any other caller of Mark and Reset), and the latency of reaching the
+// node, measured by Route for every hop: the dial and handshake of the first
+// node, or the connect and handshake through the previous node. A node is
+// ejected when it reaches the consecutive failure threshold, or when the
+// error rate or latency percentile of an evaluation window exceeds its
+// threshold. Each ejection lasts twice as long as the previous one, up to
+// MaxEjectionTime; the multiplier decays by one for every window the node
+// spends without being ejected.
+//
+// A node removed from use, e.g. from a dynamic hop, should be detached with
+// Detach, so that it no longer counts towards MaxEjectionPercent.
+//
+// Ejection is exposed through selector.Ejectable on the node's Marker, which
+// selector.FailFilter honors.
+type OutlierDetector struct {
+	options OutlierOptions
+	mu      sync.RWMutex
+	markers map[*outlierMarker]struct{}
+}
+
+// NewOutlierDetector creates an OutlierDetector.
+func NewOutlierDetector(opts ...OutlierOption) *OutlierDetector {
+	var options OutlierOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.ConsecutiveErrors <= 0 {
+		options.ConsecutiveErrors = DefaultOutlierConsecutiveErrors
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultOutlierInterval
+	}
+	if options.MinRequests <= 0 {
+		options.MinRequests = DefaultOutlierMinRequests
+	}
+	if options.BaseEjectionTime <= 0 {
+		options.BaseEjectionTime = DefaultOutlierBaseEjectionTime
+	}
+	if options.MaxEjectionTime <= 0 {
+		options.MaxEjectionTime = DefaultOutlierMaxEjectionTime
+	}
+	if options.MaxEjectionTime < options.BaseEjectionTime {
+		options.MaxEjectionTime = options.BaseEjectionTime
+	}
+	options.Logger = logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind": "outlier",
+	})
+
+	return &OutlierDetector{
+		options: options,
+		markers: make(map[*outlierMarker]struct{}),
+	}
+}
+
+// Attach installs the detector's Marker on node. It should be called before
+// node is used.
+func (d *OutlierDetector) Attach(node *Node) {
+	if node == nil {
+		return
+	}
+
+	m := &outlierMarker{
+		detector: d,
+		name:     node.Name,
+	}
+
+	d.mu.Lock()
+	d.markers[m] = struct{}{}
+	d.mu.Unlock()
+
+	node.SetMarker(m)
+}
+
+// Detach stops counting the Marker installed on node by Attach towards
+// MaxEjectionPercent. It is meant for a node whose health state is no longer
+// used: not for a node replaced by one that adopted its state with
+// Node.AdoptState, as the Marker lives on in the replacement.
+func (d *OutlierDetector) Detach(node *Node) {
+	if node == nil {
+		return
+	}
+
+	m, ok := node.Marker().(*outlierMarker)
+	if !ok || m.detector != d {
+		return
+	}
+
+	d.mu.Lock()
+	delete(d.markers, m)
+	d.mu.Unlock()
+}
+
+// mayEject reports whether one more node can be ejected without exceeding
+// MaxEjectionPercent.
+func (d *OutlierDetector) mayEject(now time.Time) bool {
+	if d.options.MaxEjectionPercent <= 0 {
+		return true
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	ejected := 0
+	for m := range d.markers {
+		if now.Before(m.EjectedUntil()) {
+			ejected++
+		}
+	}
+	return (ejected+1)*100 <= d.options.MaxEjectionPercent*len(d.markers)
+}
+
+// outlierMarker is the selector.Marker installed by OutlierDetector.Attach.
+type outlierMarker struct {
+	detector *OutlierDetector
+	name     string
+
+	// ejectedUntil is read without holding mu by OutlierDetector.mayEject.
+	ejectedUntil int64
+
+	mu          sync.Mutex
+	failTime    time.Time
+	consecutive int64
+	ejections   int
+	windowStart time.Time
+	total       int
+	failures    int
+	latencies   []time.Duration
+	samples     int
+}
+
+func (m *outlierMarker) Time() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.failTime
+}
+
+func (m *outlierMarker) Count() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.consecutive
+}
+
+// Mark records a failure.
+func (m *outlierMarker) Mark() {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roll(now)
+	m.failTime = now
+	m.consecutive++
+	m.total++
+	m.failures++
+
+	if m.consecutive >= int64(m.detector.options.ConsecutiveErrors) {
+		m.eject(now, "consecutive errors")
+	}
+}
+
+// Reset records a success.
+func (m *outlierMarker) Reset() {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roll(now)
+	m.consecutive = 0
+	m.total++
+}
+
+// EjectedUntil implements selector.Ejectable.
+func (m *outlierMarker) EjectedUntil() time.Time {
+	if v := atomic.LoadInt64(&m.ejectedUntil); v > 0 {
+		return time.Unix(0, v)
+	}
+	return time.Time{}
+}
+
+func (m *outlierMarker) observeLatency(d time.Duration) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.roll(now)
+	if len(m.latencies) < outlierLatencySamples {
+		m.latencies = append(m.latencies, d)
+	} else {
+		m.latencies[m.samples%outlierLatencySamples] = d
+	}
+	m.samples++
+}
+
+// roll evaluates and starts a new window once the current one has lasted
+// for the detector's interval. It must be called with mu held.
+func (m *outlierMarker) roll(now time.Time) {
+	opts := &m.detector.options
+
+	if m.windowStart.IsZero() {
+		m.windowStart = now
+		return
+	}
+	if now.Sub(m.windowStart) < opts.Interval {
+		return
+	}
+
+	if m.total >= opts.MinRequests {
+		if opts.ErrorRate > 0 && float64(m.failures)/float64(m.total) >= opts.ErrorRate {
+			m.eject(now, "error rate")
+		} else if opts.LatencyThreshold > 0 && percentile(m.latencies, opts.LatencyPercentile) > opts.LatencyThreshold {
+			m.eject(now, "latency")
+		}
+	}
+
+	if m.ejections > 0 && !now.Before(m.EjectedUntil().Add(opts.Interval)) {
+		m.ejections--
+	}
+
+	m.windowStart = now
+	m.total = 0
+	m.failures = 0
+	m.latencies = m.latencies[:0]
+	m.samples = 0
+}
+
+// eject ejects the node unless it is already ejected or the detector's
+// ejection cap is reached. It must be called with mu held.
+func (m *outlierMarker) eject(now time.Time, reason string) {
+	opts := &m.detector.options
+
+	if now.Before(m.EjectedUntil()) || !m.detector.mayEject(now) {
+		return
+	}
+
+	m.ejections++
+	d := opts.BaseEjectionTime
+	for i := 1; i < m.ejections && d < opts.MaxEjectionTime; i++ {
+		d *= 2
+	}
+	if d > opts.MaxEjectionTime {
+		d = opts.MaxEjectionTime
+	}
+
+	atomic.StoreInt64(&m.ejectedUntil, now.Add(d).UnixNano())
+	m.consecutive = 0
+
+	opts.Logger.Warnf("node %s ejected for %s: %s", m.name, d, reason)
+}
+
+// percentile returns the p-th percentile (0-1) of samples.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	if p <= 0 || p > 1 {
+		p = 1
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// latencyObserver is implemented by markers that record the latency of
+// reaching their node.
+type latencyObserver interface {
+	observeLatency(d time.Duration)
+}
+
+// observeLatency records d, the time taken to reach node, with the node's
+// Marker if it records latency.
+func observeLatency(node *Node, d time.Duration) {
+	if o, ok := node.Marker().(latencyObserver); ok {
+		o.observeLatency(d)
+	}
+}
+
+var _ selector.Ejectable = (*outlierMarker)(nil)
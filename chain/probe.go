@@ -6,13 +6,14 @@ import "time"
 type ProbeType string
 
 const (
-	ProbeTypeTCP ProbeType = "tcp"
+	ProbeTypeTCP  ProbeType = "tcp"
 	ProbeTypeHTTP ProbeType = "http"
 	ProbeTypeCmd  ProbeType = "cmd"
 )
 
 // ProbeConfig holds the configuration for a node-level liveness probe.
-// It is embedded in node config and drives the per-node probe goroutine.
+// It is embedded in node config and drives the per-node probe goroutine
+// (see StartProbe).
 type ProbeConfig struct {
 	Type           ProbeType
 	Addr           string
@@ -22,7 +23,10 @@ type ProbeConfig struct {
 	HTTPHost       string
 	HTTPHeaders    map[string]string
 	ExpectedStatus int
-	Command        string
+	// ExpectedHeaders lists response headers an HTTP probe requires. An
+	// empty value only requires the header to be present.
+	ExpectedHeaders map[string]string
+	Command         string
 }
 
 // ProbeResult records the outcome of a single probe attempt.
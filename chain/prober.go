@@ -0,0 +1,233 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	// DefaultProbeInterval is the probe interval used when ProbeConfig.Interval is unset.
+	DefaultProbeInterval = 10 * time.Second
+	// DefaultProbeTimeout is the probe timeout used when ProbeConfig.Timeout is unset.
+	DefaultProbeTimeout = 5 * time.Second
+)
+
+// ProbeOptions holds the runtime parameters for a node probe goroutine.
+type ProbeOptions struct {
+	// Logger is the logger for probe state changes.
+	Logger logger.Logger
+}
+
+// ProbeOption is a functional option for configuring ProbeOptions.
+type ProbeOption func(opts *ProbeOptions)
+
+// LoggerProbeOption sets the logger for the probe.
+func LoggerProbeOption(logger logger.Logger) ProbeOption {
+	return func(opts *ProbeOptions) {
+		opts.Logger = logger
+	}
+}
+
+// StartProbe starts a goroutine that probes node as described by cfg, once
+// immediately and then every cfg.Interval, storing each outcome with
+// node.SetProbeResult. The goroutine stops when node.Close is called. A
+// probe already running for node is stopped first.
+func StartProbe(node *Node, cfg *ProbeConfig, opts ...ProbeOption) {
+	if node == nil || cfg == nil {
+		return
+	}
+
+	var options ProbeOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	log := logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind":  "probe",
+		"node":  node.Name,
+		"probe": string(cfg.Type),
+	})
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+
+	node.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	node.SetProbeCancel(cancel)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		first, healthy := true, false
+		for {
+			r := Probe(ctx, node, cfg)
+			if ctx.Err() != nil {
+				return
+			}
+			node.SetProbeResult(r)
+
+			if first || healthy != r.Success {
+				if r.Success {
+					log.Debugf("probe succeeded in %s", r.Latency)
+				} else {
+					log.Warnf("probe failed: %s", r.Error)
+				}
+				first, healthy = false, r.Success
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Probe performs a single probe of node as described by cfg and returns
+// its outcome. The probe targets cfg.Addr, or node.Addr if cfg.Addr is empty,
+// and is bounded by cfg.Timeout.
+func Probe(ctx context.Context, node *Node, cfg *ProbeConfig) *ProbeResult {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := cfg.Addr
+	if addr == "" {
+		addr = node.Addr
+	}
+
+	start := time.Now()
+	var err error
+	switch cfg.Type {
+	case ProbeTypeTCP, "":
+		err = probeTCP(ctx, addr)
+	case ProbeTypeHTTP:
+		err = probeHTTP(ctx, addr, cfg)
+	case ProbeTypeCmd:
+		err = probeCmd(ctx, cfg.Command)
+	default:
+		err = fmt.Errorf("unknown probe type %s", cfg.Type)
+	}
+
+	r := &ProbeResult{
+		Success:   err == nil,
+		Latency:   time.Since(start),
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
+
+func probeTCP(ctx context.Context, addr string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func probeHTTP(ctx context.Context, addr string, cfg *ProbeConfig) error {
+	url := addr
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "http://" + addr
+	}
+	path := cfg.HTTPPath
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url += path
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if cfg.HTTPHost != "" {
+		req.Host = cfg.HTTPHost
+	}
+	for k, v := range cfg.HTTPHeaders {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if cfg.ExpectedStatus > 0 {
+		if resp.StatusCode != cfg.ExpectedStatus {
+			return fmt.Errorf("unexpected status %d, want %d", resp.StatusCode, cfg.ExpectedStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	for k, v := range cfg.ExpectedHeaders {
+		values, ok := resp.Header[http.CanonicalHeaderKey(k)]
+		if !ok {
+			return fmt.Errorf("missing header %s", k)
+		}
+		if v != "" && !slices.Contains(values, v) {
+			return fmt.Errorf("unexpected header %s: %s, want %s", k, strings.Join(values, ","), v)
+		}
+	}
+	return nil
+}
+
+// probeCmdWaitDelay bounds the wait for the output of a command probe once
+// it is canceled.
+const probeCmdWaitDelay = 100 * time.Millisecond
+
+func probeCmd(ctx context.Context, command string) error {
+	if command == "" {
+		return errors.New("empty probe command")
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	setProcessGroup(cmd)
+	// A child left running with the output pipe open would otherwise keep
+	// CombinedOutput waiting past the timeout.
+	cmd.WaitDelay = probeCmdWaitDelay
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if s := strings.TrimSpace(string(out)); s != "" {
+			return fmt.Errorf("%w: %s", err, s)
+		}
+		return err
+	}
+	return nil
+}
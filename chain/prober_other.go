@@ -0,0 +1,7 @@
+//go:build !unix
+
+package chain
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,64 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-gost/core/hosts"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/resolver"
+)
+
+// resolve replaces the host part of addr with an IP address, consulting the
+// host mapper first and then the resolver. addr is returned unchanged if it
+// has no host part, the host is already an IP address, or neither lookup
+// source is configured.
+func resolve(ctx context.Context, network, addr string, r resolver.Resolver, hm hosts.HostMapper, log logger.Logger) (string, error) {
+	if addr == "" {
+		return addr, nil
+	}
+
+	host, port, _ := net.SplitHostPort(addr)
+	if host == "" || net.ParseIP(host) != nil {
+		return addr, nil
+	}
+
+	ips, err := lookupIPs(ctx, network, host, r, hm, log)
+	if err != nil {
+		return "", err
+	}
+	if len(ips) == 0 {
+		return addr, nil
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// lookupIPs returns the IP addresses of host from the host mapper, falling
+// back to the resolver. It returns no addresses and no error if neither
+// lookup source is configured.
+func lookupIPs(ctx context.Context, network, host string, r resolver.Resolver, hm hosts.HostMapper, log logger.Logger) ([]net.IP, error) {
+	if hm != nil {
+		if ips, _ := hm.Lookup(ctx, network, host); len(ips) > 0 {
+			log.Debugf("hit host mapper: %s -> %s", host, ips)
+			return ips, nil
+		}
+	}
+
+	if r != nil {
+		ips, err := r.Resolve(ctx, network, host)
+		if err != nil {
+			if errors.Is(err, resolver.ErrInvalid) {
+				return nil, nil
+			}
+			log.Error(err)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("resolver: domain %s does not exist", host)
+		}
+		return ips, nil
+	}
+
+	return nil, nil
+}
@@ -2,9 +2,13 @@ package chain
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"time"
 
+	xnet "github.com/go-gost/core/common/net"
+	"github.com/go-gost/core/connector"
 	"github.com/go-gost/core/logger"
 )
 
@@ -31,6 +35,17 @@ type DialOptions struct {
 	SockOpts *SockOpts
 	// Logger is the logger for this dial operation.
 	Logger logger.Logger
+	// HappyEyeballs selects how a Router dials a target that resolves to
+	// several addresses. Empty means only the first address is dialed.
+	HappyEyeballs HappyEyeballsMode
+	// FallbackDelay is the delay between staggered happy eyeballs attempts.
+	FallbackDelay time.Duration
+	// Trace holds the hooks run at the stages of the dial.
+	Trace *DialTrace
+	// SessionPool shares the sessions of a multiplexed first node.
+	SessionPool *SessionPool
+	// WarmPool provides ready connections to a non-multiplexed first node.
+	WarmPool *WarmPool
 }
 
 // DialOption is a functional option for configuring DialOptions.
@@ -64,6 +79,41 @@ func LoggerDialOption(logger logger.Logger) DialOption {
 	}
 }
 
+// HappyEyeballsDialOption sets the happy eyeballs mode for the dial.
+func HappyEyeballsDialOption(mode HappyEyeballsMode) DialOption {
+	return func(opts *DialOptions) {
+		opts.HappyEyeballs = mode
+	}
+}
+
+// FallbackDelayDialOption sets the delay between happy eyeballs attempts.
+func FallbackDelayDialOption(delay time.Duration) DialOption {
+	return func(opts *DialOptions) {
+		opts.FallbackDelay = delay
+	}
+}
+
+// TraceDialOption sets the hooks run at the stages of the dial.
+func TraceDialOption(trace *DialTrace) DialOption {
+	return func(opts *DialOptions) {
+		opts.Trace = trace
+	}
+}
+
+// SessionPoolDialOption sets the pool of multiplexed sessions.
+func SessionPoolDialOption(pool *SessionPool) DialOption {
+	return func(opts *DialOptions) {
+		opts.SessionPool = pool
+	}
+}
+
+// WarmPoolDialOption sets the pool of warm first hop connections.
+func WarmPoolDialOption(pool *WarmPool) DialOption {
+	return func(opts *DialOptions) {
+		opts.WarmPool = pool
+	}
+}
+
 // BindOptions holds the runtime parameters for Bind operations.
 type BindOptions struct {
 	// Mux enables multiplexing on the bind listener.
@@ -124,3 +174,359 @@ func LoggerBindOption(logger logger.Logger) BindOption {
 		opts.Logger = logger
 	}
 }
+
+// DefaultRoute is the Route used when no proxy node is involved. It reaches
+// the target directly from the local host.
+var DefaultRoute Route = &defaultRoute{}
+
+type defaultRoute struct{}
+
+// Dial connects to address directly, honoring the interface, network
+// namespace and socket options in opts.
+func (*defaultRoute) Dial(ctx context.Context, network, address string, opts ...DialOption) (net.Conn, error) {
+	var options DialOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	d := &xnet.NetDialer{
+		Interface: options.Interface,
+		Netns:     options.Netns,
+	}
+	if options.SockOpts != nil {
+		d.Mark = options.SockOpts.Mark
+	}
+
+	conn, err := d.Dial(ctx, network, address)
+	if err != nil {
+		return nil, newDialError(nil, 0, DialPhaseDial, err)
+	}
+	return conn, nil
+}
+
+// Bind listens on address directly. Only TCP networks are supported.
+func (*defaultRoute) Bind(ctx context.Context, network, address string, opts ...BindOption) (net.Listener, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, newDialError(nil, 0, DialPhaseBind, fmt.Errorf("network %s unsupported", network))
+	}
+
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, newDialError(nil, 0, DialPhaseBind, err)
+	}
+	return ln, nil
+}
+
+func (*defaultRoute) Nodes() []*Node {
+	return nil
+}
+
+// route is a Route that forwards traffic through a fixed list of nodes.
+type route struct {
+	nodes []*Node
+}
+
+// NewRoute creates a Route that forwards traffic through nodes in order: the
+// first node is dialed and handshaken, each following node is reached by
+// Connect through the previous one, and the last node connects to the target.
+//
+// A node whose Transporter is multiplexed starts a new segment. The nodes
+// before it are handed to a copy of its Transporter as TransportOptions.Route,
+// so that the multiplexed session itself is dialed through them and can be
+// shared by later dials. Nodes then returns only the last segment.
+//
+// Dial and Bind report failures as *DialError, as does DefaultRoute, which
+// is used when there are no nodes.
+func NewRoute(nodes ...*Node) Route {
+	rt := &route{}
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+
+		if tr := node.Options().Transport; tr != nil && tr.Multiplex() && len(rt.nodes) > 0 {
+			tr = tr.Copy()
+			tr.Options().Route = rt
+			node = node.Copy()
+			node.Options().Transport = tr
+			rt = &route{}
+		}
+		rt.nodes = append(rt.nodes, node)
+	}
+	return rt
+}
+
+func (r *route) Dial(ctx context.Context, network, address string, opts ...DialOption) (net.Conn, error) {
+	if len(r.nodes) == 0 {
+		return DefaultRoute.Dial(ctx, network, address, opts...)
+	}
+
+	var options DialOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	return r.dial(ctx, network, address, &options)
+}
+
+func (r *route) dial(ctx context.Context, network, address string, options *DialOptions) (net.Conn, error) {
+	conn, warm, err := r.connect(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	hop := len(r.nodes) - 1
+	node := r.nodes[hop]
+	start := time.Now()
+	cc, err := node.Options().Transport.Connect(ctx, conn, network, address)
+	dialTrace(ctx, options).connect(NodeTraceInfo{
+		Node:     node,
+		Hop:      hop,
+		Addr:     address,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	if err != nil {
+		conn.Close()
+		if warm {
+			return r.dial(ctx, network, address, withoutWarmPool(options))
+		}
+		return nil, newDialError(node, hop, DialPhaseConnect, err)
+	}
+	return cc, nil
+}
+
+func (r *route) Bind(ctx context.Context, network, address string, opts ...BindOption) (net.Listener, error) {
+	if len(r.nodes) == 0 {
+		return DefaultRoute.Bind(ctx, network, address, opts...)
+	}
+
+	var options BindOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	conn, _, err := r.connect(ctx, &DialOptions{Logger: options.Logger})
+	if err != nil {
+		return nil, err
+	}
+
+	hop := len(r.nodes) - 1
+	ln, err := r.nodes[hop].Options().Transport.Bind(ctx, conn, network, address,
+		connector.BacklogBindOption(options.Backlog),
+		connector.MuxBindOption(options.Mux),
+		connector.UDPConnTTLBindOption(options.UDPConnTTL),
+		connector.UDPDataBufferSizeBindOption(options.UDPDataBufferSize),
+		connector.UDPDataQueueSizeBindOption(options.UDPDataQueueSize),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, newDialError(r.nodes[hop], hop, DialPhaseBind, err)
+	}
+	return ln, nil
+}
+
+func (r *route) Nodes() []*Node {
+	return r.nodes
+}
+
+// connect establishes a connection to the last node of the route. Nodes that
+// fail to resolve, dial, handshake or be connected to are marked as failed
+// and reported in a DialError; nodes that are reached successfully have
+// their marker reset.
+//
+// A warm connection to the first node may have been closed by the peer while
+// idle, which only shows when it is first used: if connecting through it
+// fails, the route is dialed afresh without blaming the next node. warm
+// reports that conn is such an unused warm connection, which is the case
+// for a route of a single node.
+func (r *route) connect(ctx context.Context, options *DialOptions) (conn net.Conn, warm bool, err error) {
+	log := logger.OrDefault(options.Logger)
+	trace := dialTrace(ctx, options)
+
+	for i, node := range r.nodes {
+		if node.Options().Transport == nil {
+			return nil, false, newDialError(node, i, DialPhaseDial, errors.New("no transport"))
+		}
+	}
+
+	node := r.nodes[0]
+	tr := firstTransport(node.Options().Transport, options)
+
+	addr, err := resolve(ctx, "ip", node.Addr, node.Options().Resolver, node.Options().HostMapper, log)
+	if err != nil {
+		markNode(ctx, node)
+		return nil, false, newDialError(node, 0, DialPhaseResolve, err)
+	}
+
+	cn, warm, err := r.dialFirst(ctx, node, tr, addr, options, trace)
+	if err != nil {
+		markNode(ctx, node)
+		return nil, false, err
+	}
+	resetNode(node)
+
+	var cc net.Conn
+	preNode := node
+	for i, node := range r.nodes[1:] {
+		hop := i + 1
+
+		addr, err = resolve(ctx, "ip", node.Addr, node.Options().Resolver, node.Options().HostMapper, log)
+		if err != nil {
+			cn.Close()
+			markNode(ctx, node)
+			return nil, false, newDialError(node, hop, DialPhaseResolve, err)
+		}
+
+		connStart := time.Now()
+		start := connStart
+		cc, err = preNode.Options().Transport.Connect(ctx, cn, "tcp", addr)
+		trace.connect(NodeTraceInfo{
+			Node:     preNode,
+			Hop:      hop - 1,
+			Addr:     addr,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		if err != nil {
+			cn.Close()
+			if warm {
+				return r.connect(ctx, withoutWarmPool(options))
+			}
+			markNode(ctx, node)
+			return nil, false, newDialError(node, hop, DialPhaseConnect, err)
+		}
+		warm = false
+
+		start = time.Now()
+		cc, err = node.Options().Transport.Handshake(ctx, cc)
+		trace.handshake(NodeTraceInfo{
+			Node:     node,
+			Hop:      hop,
+			Addr:     addr,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		if err != nil {
+			cn.Close()
+			markNode(ctx, node)
+			return nil, false, newDialError(node, hop, DialPhaseHandshake, err)
+		}
+		observeLatency(node, time.Since(connStart))
+		resetNode(node)
+
+		cn = cc
+		preNode = node
+	}
+
+	return cn, warm, nil
+}
+
+// dialFirst dials and handshakes the first node of the route. If the node
+// is multiplexed and a SessionPool is in use, a stream is opened on a pooled
+// session; otherwise a ready connection is taken from the WarmPool in use,
+// if any, without firing the Dial and Handshake trace hooks, and warm is
+// set.
+func (r *route) dialFirst(ctx context.Context, node *Node, tr Transporter, addr string, options *DialOptions, trace *DialTrace) (conn net.Conn, warm bool, err error) {
+	if tr.Multiplex() {
+		if options.SessionPool != nil {
+			conn, err = options.SessionPool.stream(ctx, node, 0, tr, addr, trace)
+			return conn, false, err
+		}
+	} else if options.WarmPool != nil {
+		if conn := options.WarmPool.get(node, tr, addr); conn != nil {
+			return conn, true, nil
+		}
+	}
+
+	dialStart := time.Now()
+	start := dialStart
+	cc, err := tr.Dial(ctx, addr)
+	trace.dial(NodeTraceInfo{
+		Node:     node,
+		Hop:      0,
+		Addr:     addr,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	if err != nil {
+		return nil, false, newDialError(node, 0, DialPhaseDial, err)
+	}
+
+	start = time.Now()
+	cn, err := tr.Handshake(ctx, cc)
+	trace.handshake(NodeTraceInfo{
+		Node:     node,
+		Hop:      0,
+		Addr:     addr,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	if err != nil {
+		// Without a SessionPool, the connection of a multiplexed transport
+		// is a session owned by the Transporter, so it is left for the
+		// Transporter to close.
+		if !tr.Multiplex() {
+			cc.Close()
+		}
+		return nil, false, newDialError(node, 0, DialPhaseHandshake, err)
+	}
+	observeLatency(node, time.Since(dialStart))
+	return cn, false, nil
+}
+
+// withoutWarmPool returns a copy of options that does not use a WarmPool.
+func withoutWarmPool(options *DialOptions) *DialOptions {
+	opts := *options
+	opts.WarmPool = nil
+	return &opts
+}
+
+// firstTransport returns the Transporter used to dial the first node. The
+// first hop is dialed from the local host, so the interface, network
+// namespace and socket options in options are applied to a copy of tr.
+func firstTransport(tr Transporter, options *DialOptions) Transporter {
+	if options.Interface == "" && options.Netns == "" && options.SockOpts == nil {
+		return tr
+	}
+
+	tr = tr.Copy()
+	if o := tr.Options(); o != nil {
+		if options.Interface != "" {
+			o.IfceName = options.Interface
+		}
+		if options.Netns != "" {
+			o.Netns = options.Netns
+		}
+		if options.SockOpts != nil {
+			o.SockOpts = options.SockOpts
+		}
+	}
+	return tr
+}
+
+// markNode marks node as failed, unless the failure is due to the dial
+// being canceled, e.g. a losing happy eyeballs attempt.
+func markNode(ctx context.Context, node *Node) {
+	if ctx.Err() == context.Canceled {
+		return
+	}
+	if marker := node.Marker(); marker != nil {
+		marker.Mark()
+	}
+}
+
+func resetNode(node *Node) {
+	if marker := node.Marker(); marker != nil {
+		marker.Reset()
+	}
+}
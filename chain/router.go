@@ -1,8 +1,12 @@
 package chain
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/go-gost/core/hosts"
@@ -21,8 +25,10 @@ type SockOpts struct {
 type RouterOptions struct {
 	// Retries is the number of dial retries on failure.
 	Retries int
-	// Timeout is the dial timeout.
+	// Timeout is the dial timeout, covering all attempts.
 	Timeout time.Duration
+	// AttemptTimeout is the timeout of a single dial attempt.
+	AttemptTimeout time.Duration
 	// IfceName is the network interface name to bind to.
 	IfceName string
 	// Netns is the network namespace name.
@@ -72,6 +78,13 @@ func TimeoutRouterOption(timeout time.Duration) RouterOption {
 	}
 }
 
+// AttemptTimeoutRouterOption sets the timeout of a single dial attempt.
+func AttemptTimeoutRouterOption(timeout time.Duration) RouterOption {
+	return func(o *RouterOptions) {
+		o.AttemptTimeout = timeout
+	}
+}
+
 // RetriesRouterOption sets the number of dial retries.
 func RetriesRouterOption(retries int) RouterOption {
 	return func(o *RouterOptions) {
@@ -126,3 +139,316 @@ type Router interface {
 	// Bind creates a reverse listener through the configured chain.
 	Bind(ctx context.Context, network, address string, opts ...BindOption) (net.Listener, error)
 }
+
+var (
+	// ErrNoRoute is returned by a Router when its Chainer has no route to
+	// the target.
+	ErrNoRoute = errors.New("no route to host")
+)
+
+// DefaultRouterTimeout is the dial timeout of a Router created by NewRouter
+// when none is configured.
+const DefaultRouterTimeout = 15 * time.Second
+
+type router struct {
+	options RouterOptions
+}
+
+// NewRouter creates a Router. Each Dial records the target host with the
+// RecorderServiceRouterDialAddress recorders, then makes up to Retries+1
+// attempts. An attempt maps the target through the HostMapper, falling back
+// to the Resolver, asks the Chainer for a Route and dials through it; with
+// no Chainer the target is dialed directly. Failing nodes are marked by the
+// Route. Timeout bounds the whole Dial and AttemptTimeout each attempt. If
+// every attempt fails, the target host is recorded with the
+// RecorderServiceRouterDialAddressError recorders. Failures to resolve the
+// target and errors from a Route are *DialError carrying the failing
+// attempt number; ErrNoRoute and context errors are returned as is.
+func NewRouter(opts ...RouterOption) Router {
+	var options RouterOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = DefaultRouterTimeout
+	}
+	options.Logger = logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind": "router",
+	})
+
+	return &router{
+		options: options,
+	}
+}
+
+func (r *router) Options() *RouterOptions {
+	return &r.options
+}
+
+func (r *router) Dial(ctx context.Context, network, address string, opts ...DialOption) (net.Conn, error) {
+	host := address
+	if h, _, _ := net.SplitHostPort(address); h != "" {
+		host = h
+	}
+	r.record(ctx, recorder.RecorderServiceRouterDialAddress, []byte(host))
+
+	conn, err := r.dial(ctx, network, address, opts...)
+	if err != nil {
+		r.record(ctx, recorder.RecorderServiceRouterDialAddressError, []byte(host))
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (r *router) dial(ctx context.Context, network, address string, opts ...DialOption) (conn net.Conn, err error) {
+	if r.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.options.Timeout)
+		defer cancel()
+	}
+
+	log := r.options.Logger
+	log.Debugf("dial %s/%s", address, network)
+
+	opts = append([]DialOption{
+		InterfaceDialOption(r.options.IfceName),
+		NetnsDialOption(r.options.Netns),
+		SockOptsDialOption(r.options.SockOpts),
+		LoggerDialOption(log),
+	}, opts...)
+
+	var options DialOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	trace := dialTrace(ctx, &options)
+	start := time.Now()
+	attempts := 0
+	defer func() {
+		trace.done(DoneTraceInfo{
+			Attempts: attempts,
+			Conn:     conn,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}()
+
+	count := r.options.Retries + 1
+	if count <= 0 {
+		count = 1
+	}
+
+	for i := 0; i < count; i++ {
+		if ctx.Err() != nil {
+			if err == nil {
+				err = ctx.Err()
+			}
+			return
+		}
+		if i > 0 {
+			trace.retry(RetryTraceInfo{
+				Attempt: i,
+				Err:     err,
+			})
+		}
+		attempts++
+
+		host, _, _ := net.SplitHostPort(address)
+		trace.resolveStart(host)
+		resolveStart := time.Now()
+		var addrs []string
+		addrs, err = r.resolve(ctx, network, address, options.HappyEyeballs)
+		trace.resolveDone(ResolveTraceInfo{
+			Host:     host,
+			Addrs:    addrs,
+			Duration: time.Since(resolveStart),
+			Err:      err,
+		})
+		if err != nil {
+			err = newDialError(nil, 0, DialPhaseResolve, err)
+			setAttempt(err, i)
+			log.Error(err)
+			return
+		}
+
+		var route Route
+		route, err = r.route(ctx, network, addrs[0], address)
+		if err != nil {
+			log.Errorf("route(retry=%d) %s: %v", i, address, err)
+			continue
+		}
+
+		if log.IsLevelEnabled(logger.DebugLevel) {
+			log.Debugf("route(retry=%d) %s", i, routePath(route, strings.Join(addrs, ",")))
+		}
+
+		conn, err = r.dialAttempt(ctx, route, network, addrs, &options, opts...)
+		if err == nil {
+			return
+		}
+		setAttempt(err, i)
+		log.Errorf("route(retry=%d) %s", i, err)
+	}
+
+	return
+}
+
+// resolve returns the addresses to dial for address. Without a happy
+// eyeballs mode, or when the host is not a name, it is a single address.
+func (r *router) resolve(ctx context.Context, network, address string, mode HappyEyeballsMode) ([]string, error) {
+	host, port, _ := net.SplitHostPort(address)
+	if mode == "" || host == "" || net.ParseIP(host) != nil {
+		addr, err := resolve(ctx, "ip", address, r.options.Resolver, r.options.HostMapper, r.options.Logger)
+		if err != nil {
+			return nil, err
+		}
+		return []string{addr}, nil
+	}
+
+	ipNetwork := "ip"
+	switch network {
+	case "tcp4", "udp4":
+		ipNetwork = "ip4"
+	case "tcp6", "udp6":
+		ipNetwork = "ip6"
+	}
+
+	ips, err := lookupIPs(ctx, ipNetwork, host, r.options.Resolver, r.options.HostMapper, r.options.Logger)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return []string{address}, nil
+	}
+
+	var addrs []string
+	for _, ip := range sortIPs(ips, mode) {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+	}
+	return addrs, nil
+}
+
+// dialAttempt dials addrs through route, racing them with happy eyeballs
+// if there are several.
+func (r *router) dialAttempt(ctx context.Context, route Route, network string, addrs []string, options *DialOptions, opts ...DialOption) (net.Conn, error) {
+	if r.options.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.options.AttemptTimeout)
+		defer cancel()
+	}
+
+	if len(addrs) == 1 {
+		return route.Dial(ctx, network, addrs[0], opts...)
+	}
+
+	return dialHappyEyeballs(ctx, addrs, options.FallbackDelay, options.HappyEyeballs == HappyEyeballsRace,
+		func(ctx context.Context, addr string) (net.Conn, error) {
+			return route.Dial(ctx, network, addr, opts...)
+		})
+}
+
+// route returns the Route to address. host is the target before
+// resolution, used for host-based routing.
+func (r *router) route(ctx context.Context, network, address, host string) (Route, error) {
+	if r.options.Chain == nil {
+		return DefaultRoute, nil
+	}
+
+	route := r.options.Chain.Route(ctx, network, address, WithHostRouteOption(host))
+	if route == nil {
+		return nil, ErrNoRoute
+	}
+	return route, nil
+}
+
+func (r *router) Bind(ctx context.Context, network, address string, opts ...BindOption) (ln net.Listener, err error) {
+	log := r.options.Logger
+	log.Debugf("bind on %s/%s", address, network)
+
+	opts = append([]BindOption{
+		LoggerBindOption(log),
+	}, opts...)
+
+	count := r.options.Retries + 1
+	if count <= 0 {
+		count = 1
+	}
+
+	for i := 0; i < count; i++ {
+		var route Route
+		route, err = r.route(ctx, network, address, address)
+		if err != nil {
+			log.Errorf("route(retry=%d) %s: %v", i, address, err)
+			continue
+		}
+
+		if log.IsLevelEnabled(logger.DebugLevel) {
+			log.Debugf("route(retry=%d) %s", i, routePath(route, address))
+		}
+
+		ln, err = route.Bind(ctx, network, address, opts...)
+		if err == nil {
+			return
+		}
+		setAttempt(err, i)
+		log.Errorf("route(retry=%d) %s", i, err)
+	}
+
+	return
+}
+
+// setAttempt records the Router attempt number in the DialError of err.
+func setAttempt(err error, attempt int) {
+	var de *DialError
+	if errors.As(err, &de) {
+		de.Attempt = attempt
+	}
+}
+
+func (r *router) record(ctx context.Context, name string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	for _, rec := range r.options.Recorders {
+		if rec.Record != name || rec.Recorder == nil {
+			continue
+		}
+		if err := rec.Recorder.Record(ctx, data, recorder.MetadataRecordOption(rec.Metadata)); err != nil {
+			r.options.Logger.Errorf("record %s: %v", name, err)
+		}
+	}
+}
+
+// routePath formats the nodes of route, including those reached through
+// multiplexed transports, followed by the target address.
+func routePath(route Route, address string) string {
+	var buf bytes.Buffer
+	for _, node := range routeNodes(route) {
+		fmt.Fprintf(&buf, "%s@%s > ", node.Name, node.Addr)
+	}
+	buf.WriteString(address)
+	return buf.String()
+}
+
+func routeNodes(route Route) (nodes []*Node) {
+	if route == nil {
+		return
+	}
+
+	for _, node := range route.Nodes() {
+		if tr := node.Options().Transport; tr != nil {
+			if o := tr.Options(); o != nil && o.Route != nil {
+				nodes = append(nodes, routeNodes(o.Route)...)
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return
+}
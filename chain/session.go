@@ -0,0 +1,317 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+// DefaultSessionIdleTimeout is the idle timeout of pooled sessions when none
+// is configured.
+const DefaultSessionIdleTimeout = 90 * time.Second
+
+// SessionPoolOptions holds the initialization parameters for a SessionPool.
+type SessionPoolOptions struct {
+	// MaxStreams is the maximum number of concurrent streams per session
+	// (0 = unlimited).
+	MaxStreams int
+	// IdleTimeout is how long a session without streams is kept.
+	IdleTimeout time.Duration
+	// Logger is the logger for pool operations.
+	Logger logger.Logger
+}
+
+// SessionPoolOption is a functional option for configuring SessionPoolOptions.
+type SessionPoolOption func(opts *SessionPoolOptions)
+
+// MaxStreamsSessionPoolOption sets the maximum number of streams per session.
+func MaxStreamsSessionPoolOption(n int) SessionPoolOption {
+	return func(opts *SessionPoolOptions) {
+		opts.MaxStreams = n
+	}
+}
+
+// IdleTimeoutSessionPoolOption sets the idle timeout of sessions.
+func IdleTimeoutSessionPoolOption(timeout time.Duration) SessionPoolOption {
+	return func(opts *SessionPoolOptions) {
+		opts.IdleTimeout = timeout
+	}
+}
+
+// LoggerSessionPoolOption sets the logger.
+func LoggerSessionPoolOption(logger logger.Logger) SessionPoolOption {
+	return func(opts *SessionPoolOptions) {
+		opts.Logger = logger
+	}
+}
+
+// SessionPool keeps the sessions established with nodes whose Transporter is
+// multiplexed, so that Route dials share them instead of dialing the node
+// each time. A session is the connection returned by Transporter.Dial; each
+// Route dial opens a stream on it with Transporter.Handshake. Sessions are
+// keyed by node name and address, hold at most MaxStreams streams, are
+// closed after IdleTimeout without streams, and are retired when opening a
+// stream on them fails for a reason other than the dial being canceled: no
+// stream is opened on a retired session any more, and it is closed once its
+// last stream is closed.
+//
+// The pool owns the sessions it dials: it is the only one to close them.
+// Without a pool, the session of a multiplexed Transporter is left to the
+// Transporter.
+//
+// A pool is used by passing it to Route.Dial with SessionPoolDialOption.
+// It applies to the first node of the route.
+type SessionPool struct {
+	options  SessionPoolOptions
+	mu       sync.Mutex
+	sessions map[string][]*session
+	done     chan struct{}
+	once     sync.Once
+}
+
+type session struct {
+	key       string
+	conn      net.Conn
+	streams   int
+	idleSince time.Time
+	// retired is set once s is out of the pool: it is closed when its last
+	// stream is released.
+	retired bool
+}
+
+// NewSessionPool creates a SessionPool.
+func NewSessionPool(opts ...SessionPoolOption) *SessionPool {
+	var options SessionPoolOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.IdleTimeout <= 0 {
+		options.IdleTimeout = DefaultSessionIdleTimeout
+	}
+	options.Logger = logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind": "session-pool",
+	})
+
+	p := &SessionPool{
+		options:  options,
+		sessions: make(map[string][]*session),
+		done:     make(chan struct{}),
+	}
+	go p.reap()
+
+	return p
+}
+
+// Close closes all pooled sessions and stops the pool.
+func (p *SessionPool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		for key, sessions := range p.sessions {
+			for _, s := range sessions {
+				s.conn.Close()
+			}
+			delete(p.sessions, key)
+		}
+	})
+	return nil
+}
+
+// stream returns a new stream to node at addr, opened on a pooled session
+// if one has a free stream slot, or else on a newly dialed session. A
+// session on which the stream cannot be opened is retired, and a stream on
+// a fresh session is tried once more.
+func (p *SessionPool) stream(ctx context.Context, node *Node, hop int, tr Transporter, addr string, trace *DialTrace) (net.Conn, error) {
+	key := node.Name + "@" + addr
+
+	for {
+		s := p.acquire(key)
+		reused := s != nil
+		streamStart := time.Now()
+		if !reused {
+			start := time.Now()
+			conn, err := tr.Dial(ctx, addr)
+			trace.dial(NodeTraceInfo{
+				Node:     node,
+				Hop:      hop,
+				Addr:     addr,
+				Duration: time.Since(start),
+				Err:      err,
+			})
+			if err != nil {
+				return nil, newDialError(node, hop, DialPhaseDial, err)
+			}
+			s = p.add(key, conn)
+		}
+
+		start := time.Now()
+		cn, err := tr.Handshake(ctx, s.conn)
+		trace.handshake(NodeTraceInfo{
+			Node:     node,
+			Hop:      hop,
+			Addr:     addr,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+		if err != nil {
+			// A canceled dial, e.g. the losing attempt of happy eyeballs,
+			// says nothing about the session, which stays pooled.
+			if ctx.Err() != nil {
+				p.release(s)
+				return nil, newDialError(node, hop, DialPhaseHandshake, err)
+			}
+			p.retire(s)
+			if reused {
+				continue
+			}
+			return nil, newDialError(node, hop, DialPhaseHandshake, err)
+		}
+		observeLatency(node, time.Since(streamStart))
+
+		return &streamConn{
+			Conn: cn,
+			release: func() {
+				p.release(s)
+			},
+		}, nil
+	}
+}
+
+// acquire reserves a stream slot on a pooled session for key.
+func (p *SessionPool) acquire(key string) *session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, s := range p.sessions[key] {
+		if p.options.MaxStreams <= 0 || s.streams < p.options.MaxStreams {
+			s.streams++
+			return s
+		}
+	}
+	return nil
+}
+
+// add pools a new session for key with one stream slot reserved.
+func (p *SessionPool) add(key string, conn net.Conn) *session {
+	s := &session{
+		key:     key,
+		conn:    conn,
+		streams: 1,
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		// The pool is closed: the session serves this stream only.
+		s.retired = true
+	default:
+		p.sessions[key] = append(p.sessions[key], s)
+	}
+	return s
+}
+
+// release frees a stream slot of s, closing s if it is retired and has no
+// stream left.
+func (p *SessionPool) release(s *session) {
+	p.mu.Lock()
+	s.streams--
+	if s.streams <= 0 {
+		s.streams = 0
+		s.idleSince = time.Now()
+	}
+	closing := s.retired && s.streams == 0
+	p.mu.Unlock()
+
+	if closing {
+		s.conn.Close()
+		p.options.Logger.Debugf("session %s closed after retirement", s.key)
+	}
+}
+
+// retire removes s from the pool, so that no stream is opened on it any
+// more, and frees the stream slot reserved on it. The streams already open
+// on s are left alone: a failure to open a stream, e.g. at a stream limit
+// of the server, does not mean that the session is broken.
+func (p *SessionPool) retire(s *session) {
+	p.mu.Lock()
+	if !s.retired {
+		p.remove(s)
+		s.retired = true
+		p.options.Logger.Debugf("session %s retired", s.key)
+	}
+	p.mu.Unlock()
+
+	p.release(s)
+}
+
+// remove removes s from the pool. It must be called with mu held.
+func (p *SessionPool) remove(s *session) {
+	sessions := p.sessions[s.key]
+	for i := range sessions {
+		if sessions[i] == s {
+			sessions = append(sessions[:i], sessions[i+1:]...)
+			break
+		}
+	}
+	if len(sessions) == 0 {
+		delete(p.sessions, s.key)
+	} else {
+		p.sessions[s.key] = sessions
+	}
+}
+
+// reap periodically closes sessions that have been idle for IdleTimeout.
+func (p *SessionPool) reap() {
+	ticker := time.NewTicker(p.options.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		var idle []*session
+		p.mu.Lock()
+		for _, sessions := range p.sessions {
+			for _, s := range sessions {
+				if s.streams == 0 && time.Since(s.idleSince) >= p.options.IdleTimeout {
+					idle = append(idle, s)
+				}
+			}
+		}
+		for _, s := range idle {
+			p.remove(s)
+		}
+		p.mu.Unlock()
+
+		for _, s := range idle {
+			s.conn.Close()
+			p.options.Logger.Debugf("session %s closed after idle timeout", s.key)
+		}
+	}
+}
+
+// streamConn is a stream on a pooled session. Closing it frees its slot.
+type streamConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *streamConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// DialTrace is a set of hooks run at the stages of a dial through a Router
+// or Route, in the manner of net/http/httptrace. Any hook may be nil. Hooks
+// may be called concurrently, e.g. by happy eyeballs attempts.
+//
+// A trace is passed with TraceDialOption or attached to the dial context
+// with ContextWithDialTrace; the option takes precedence.
+type DialTrace struct {
+	// ResolveStart is called by a Router before the target host is resolved.
+	ResolveStart func(host string)
+	// ResolveDone is called by a Router after the target host is resolved.
+	ResolveDone func(ResolveTraceInfo)
+	// Dial is called after the Transporter of the first node of a Route
+	// dials the node.
+	Dial func(NodeTraceInfo)
+	// Handshake is called after the Transporter of a node completes its
+	// handshake on the connection to the node.
+	Handshake func(NodeTraceInfo)
+	// Connect is called after the Transporter of a node connects through
+	// the node to the next node or, for the last node, to the target.
+	Connect func(NodeTraceInfo)
+	// Retry is called by a Router when an attempt fails and another one
+	// follows.
+	Retry func(RetryTraceInfo)
+	// Done is called by a Router when Dial returns.
+	Done func(DoneTraceInfo)
+}
+
+// ResolveTraceInfo is passed to DialTrace.ResolveDone.
+type ResolveTraceInfo struct {
+	// Host is the resolved host.
+	Host string
+	// Addrs are the addresses that will be dialed.
+	Addrs []string
+	// Duration is the time spent resolving.
+	Duration time.Duration
+	// Err is the resolution error, if any.
+	Err error
+}
+
+// NodeTraceInfo is passed to the per-node hooks of a DialTrace.
+type NodeTraceInfo struct {
+	// Node is the node whose Transporter performed the step.
+	Node *Node
+	// Hop is the index of Node in the Route.
+	Hop int
+	// Addr is the address dialed or connected to.
+	Addr string
+	// Duration is the time spent in the step.
+	Duration time.Duration
+	// Err is the error of the step, if any.
+	Err error
+}
+
+// RetryTraceInfo is passed to DialTrace.Retry.
+type RetryTraceInfo struct {
+	// Attempt is the zero-based number of the attempt about to start.
+	Attempt int
+	// Err is the error of the previous attempt.
+	Err error
+}
+
+// DoneTraceInfo is passed to DialTrace.Done.
+type DoneTraceInfo struct {
+	// Attempts is the number of attempts made.
+	Attempts int
+	// Conn is the established connection, if any.
+	Conn net.Conn
+	// Duration is the total time spent in Dial.
+	Duration time.Duration
+	// Err is the final error, if any.
+	Err error
+}
+
+type dialTraceKey struct{}
+
+// ContextWithDialTrace returns a context carrying trace.
+func ContextWithDialTrace(ctx context.Context, trace *DialTrace) context.Context {
+	return context.WithValue(ctx, dialTraceKey{}, trace)
+}
+
+// DialTraceFromContext returns the DialTrace carried by ctx, or nil.
+func DialTraceFromContext(ctx context.Context) *DialTrace {
+	if ctx == nil {
+		return nil
+	}
+	trace, _ := ctx.Value(dialTraceKey{}).(*DialTrace)
+	return trace
+}
+
+// dialTrace returns the trace of a dial: the one set in options, or else
+// the one carried by ctx.
+func dialTrace(ctx context.Context, options *DialOptions) *DialTrace {
+	if options.Trace != nil {
+		return options.Trace
+	}
+	return DialTraceFromContext(ctx)
+}
+
+func (t *DialTrace) resolveStart(host string) {
+	if t != nil && t.ResolveStart != nil {
+		t.ResolveStart(host)
+	}
+}
+
+func (t *DialTrace) resolveDone(info ResolveTraceInfo) {
+	if t != nil && t.ResolveDone != nil {
+		t.ResolveDone(info)
+	}
+}
+
+func (t *DialTrace) dial(info NodeTraceInfo) {
+	if t != nil && t.Dial != nil {
+		t.Dial(info)
+	}
+}
+
+func (t *DialTrace) handshake(info NodeTraceInfo) {
+	if t != nil && t.Handshake != nil {
+		t.Handshake(info)
+	}
+}
+
+func (t *DialTrace) connect(info NodeTraceInfo) {
+	if t != nil && t.Connect != nil {
+		t.Connect(info)
+	}
+}
+
+func (t *DialTrace) retry(info RetryTraceInfo) {
+	if t != nil && t.Retry != nil {
+		t.Retry(info)
+	}
+}
+
+func (t *DialTrace) done(info DoneTraceInfo) {
+	if t != nil && t.Done != nil {
+		t.Done(info)
+	}
+}
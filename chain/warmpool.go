@@ -0,0 +1,312 @@
+package chain
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/metadata"
+)
+
+const (
+	// MDKeyWarmPoolSize is the node metadata key enabling a WarmPool for the
+	// node: the number of ready connections to keep.
+	MDKeyWarmPoolSize = "warmPool.size"
+	// MDKeyWarmPoolMaxIdle is the node metadata key overriding the maximum
+	// idle age of the node's warm connections.
+	MDKeyWarmPoolMaxIdle = "warmPool.maxIdle"
+)
+
+const (
+	// DefaultWarmPoolMaxIdle is the maximum idle age of warm connections
+	// when none is configured.
+	DefaultWarmPoolMaxIdle = 30 * time.Second
+	// DefaultWarmPoolDialTimeout is the timeout of background dials when
+	// none is configured.
+	DefaultWarmPoolDialTimeout = 10 * time.Second
+	// DefaultWarmPoolInactiveTimeout is how long a node's connections are
+	// kept warm after the node was last used when none is configured.
+	DefaultWarmPoolInactiveTimeout = 5 * time.Minute
+)
+
+// WarmPoolOptions holds the initialization parameters for a WarmPool.
+type WarmPoolOptions struct {
+	// MaxIdle is the maximum age of an unused warm connection.
+	MaxIdle time.Duration
+	// DialTimeout is the timeout of a background dial and handshake.
+	DialTimeout time.Duration
+	// InactiveTimeout stops refilling a node that has not been used for
+	// this long.
+	InactiveTimeout time.Duration
+	// Logger is the logger for pool operations.
+	Logger logger.Logger
+}
+
+// WarmPoolOption is a functional option for configuring WarmPoolOptions.
+type WarmPoolOption func(opts *WarmPoolOptions)
+
+// MaxIdleWarmPoolOption sets the maximum idle age of warm connections.
+func MaxIdleWarmPoolOption(d time.Duration) WarmPoolOption {
+	return func(opts *WarmPoolOptions) {
+		opts.MaxIdle = d
+	}
+}
+
+// DialTimeoutWarmPoolOption sets the timeout of background dials.
+func DialTimeoutWarmPoolOption(d time.Duration) WarmPoolOption {
+	return func(opts *WarmPoolOptions) {
+		opts.DialTimeout = d
+	}
+}
+
+// InactiveTimeoutWarmPoolOption sets how long unused nodes are kept warm.
+func InactiveTimeoutWarmPoolOption(d time.Duration) WarmPoolOption {
+	return func(opts *WarmPoolOptions) {
+		opts.InactiveTimeout = d
+	}
+}
+
+// LoggerWarmPoolOption sets the logger.
+func LoggerWarmPoolOption(logger logger.Logger) WarmPoolOption {
+	return func(opts *WarmPoolOptions) {
+		opts.Logger = logger
+	}
+}
+
+// WarmPool keeps pre-dialed and handshaken connections to the first node of
+// routes whose Transporter is not multiplexed, hiding the dial latency of
+// the first hop. It is enabled per node by the MDKeyWarmPoolSize metadata,
+// which sets how many connections are kept ready. The pool is filled in the
+// background on first use of a node and refilled as connections are taken;
+// connections unused for MaxIdle (or the node's MDKeyWarmPoolMaxIdle) are
+// discarded, and nodes unused for InactiveTimeout are no longer refilled.
+// A warm connection found closed by the peer when Route first connects
+// through it is replaced by a fresh dial.
+//
+// A pool is used by passing it to Route.Dial with WarmPoolDialOption.
+type WarmPool struct {
+	options WarmPoolOptions
+	mu      sync.Mutex
+	nodes   map[string]*warmNode
+	done    chan struct{}
+	once    sync.Once
+}
+
+type warmNode struct {
+	key      string
+	node     *Node
+	tr       Transporter
+	addr     string
+	size     int
+	maxIdle  time.Duration
+	conns    []warmConn
+	filling  int
+	lastUsed time.Time
+}
+
+type warmConn struct {
+	conn    net.Conn
+	created time.Time
+}
+
+// NewWarmPool creates a WarmPool.
+func NewWarmPool(opts ...WarmPoolOption) *WarmPool {
+	var options WarmPoolOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.MaxIdle <= 0 {
+		options.MaxIdle = DefaultWarmPoolMaxIdle
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = DefaultWarmPoolDialTimeout
+	}
+	if options.InactiveTimeout <= 0 {
+		options.InactiveTimeout = DefaultWarmPoolInactiveTimeout
+	}
+	options.Logger = logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind": "warm-pool",
+	})
+
+	p := &WarmPool{
+		options: options,
+		nodes:   make(map[string]*warmNode),
+		done:    make(chan struct{}),
+	}
+	go p.maintain()
+
+	return p
+}
+
+// Close closes all warm connections and stops the pool.
+func (p *WarmPool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		for key, wn := range p.nodes {
+			for _, wc := range wn.conns {
+				wc.conn.Close()
+			}
+			delete(p.nodes, key)
+		}
+	})
+	return nil
+}
+
+// get returns a warm connection to node at addr, or nil if the pool is not
+// enabled for node or has no connection ready. Either way the node's pool
+// is refilled in the background.
+func (p *WarmPool) get(node *Node, tr Transporter, addr string) net.Conn {
+	size := metadata.GetInt(node.Metadata(), MDKeyWarmPoolSize)
+	if size <= 0 {
+		return nil
+	}
+
+	key := node.Name + "@" + addr
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+
+	wn := p.nodes[key]
+	if wn == nil {
+		maxIdle := metadata.GetDuration(node.Metadata(), MDKeyWarmPoolMaxIdle)
+		if maxIdle <= 0 {
+			maxIdle = p.options.MaxIdle
+		}
+		wn = &warmNode{
+			key:     key,
+			node:    node,
+			tr:      tr,
+			addr:    addr,
+			maxIdle: maxIdle,
+		}
+		p.nodes[key] = wn
+	}
+	wn.size = size
+	wn.lastUsed = now
+
+	var conn net.Conn
+	for len(wn.conns) > 0 && conn == nil {
+		wc := wn.conns[0]
+		wn.conns = wn.conns[1:]
+		if now.Sub(wc.created) >= wn.maxIdle {
+			wc.conn.Close()
+			continue
+		}
+		conn = wc.conn
+	}
+
+	p.refill(wn)
+	return conn
+}
+
+// refill starts background dials until wn has size connections ready or
+// being dialed. It must be called with mu held.
+func (p *WarmPool) refill(wn *warmNode) {
+	for len(wn.conns)+wn.filling < wn.size {
+		wn.filling++
+		go p.fill(wn)
+	}
+}
+
+func (p *WarmPool) fill(wn *warmNode) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.options.DialTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := wn.tr.Dial(ctx, wn.addr)
+	if err == nil {
+		var cc net.Conn
+		if cc, err = wn.tr.Handshake(ctx, conn); err != nil {
+			conn.Close()
+		}
+		conn = cc
+	}
+	if err == nil {
+		observeLatency(wn.node, time.Since(start))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wn.filling--
+	if err != nil {
+		p.options.Logger.Debugf("warm %s: %v", wn.key, err)
+		return
+	}
+
+	select {
+	case <-p.done:
+		conn.Close()
+		return
+	default:
+	}
+	if p.nodes[wn.key] != wn || len(wn.conns) >= wn.size {
+		conn.Close()
+		return
+	}
+	wn.conns = append(wn.conns, warmConn{
+		conn:    conn,
+		created: time.Now(),
+	})
+}
+
+// maintain periodically discards expired connections, refills active
+// nodes and drops inactive ones.
+func (p *WarmPool) maintain() {
+	ticker := time.NewTicker(p.options.MaxIdle / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		now := time.Now()
+		var expired []net.Conn
+
+		p.mu.Lock()
+		for key, wn := range p.nodes {
+			conns := wn.conns[:0]
+			for _, wc := range wn.conns {
+				if now.Sub(wc.created) >= wn.maxIdle {
+					expired = append(expired, wc.conn)
+				} else {
+					conns = append(conns, wc)
+				}
+			}
+			wn.conns = conns
+
+			if now.Sub(wn.lastUsed) >= p.options.InactiveTimeout {
+				for _, wc := range wn.conns {
+					expired = append(expired, wc.conn)
+				}
+				delete(p.nodes, key)
+				continue
+			}
+			p.refill(wn)
+		}
+		p.mu.Unlock()
+
+		for _, conn := range expired {
+			conn.Close()
+		}
+	}
+}
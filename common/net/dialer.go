@@ -4,7 +4,9 @@ package net
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"time"
 )
 
 // Dialer is a minimal interface for establishing network connections.
@@ -13,3 +15,107 @@ import (
 type Dialer interface {
 	Dial(ctx context.Context, network, addr string) (net.Conn, error)
 }
+
+// NetDialer is a stdlib-based Dialer that honors the local interface,
+// network namespace and socket mark settings carried by chain.DialOptions
+// and chain.TransportOptions.
+type NetDialer struct {
+	// Interface is a network interface name or a local IP address to
+	// dial from.
+	Interface string
+	// Netns is the network namespace name or path to dial in.
+	Netns string
+	// Mark is the SO_MARK value set on the socket (0 = unset).
+	Mark int
+	// Timeout is the connect timeout (0 = no timeout).
+	Timeout time.Duration
+}
+
+// Dial connects to addr on the named network. If Netns is set, the socket
+// is created inside that network namespace.
+func (d *NetDialer) Dial(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	if d == nil {
+		d = &NetDialer{}
+	}
+
+	if d.Netns == "" {
+		return d.dial(ctx, network, addr)
+	}
+
+	err = withNetns(d.Netns, func() error {
+		conn, err = d.dial(ctx, network, addr)
+		return err
+	})
+	return
+}
+
+func (d *NetDialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	nd := net.Dialer{
+		Timeout: d.Timeout,
+		Control: d.control,
+	}
+
+	if d.Interface == "" {
+		return nd.DialContext(ctx, network, addr)
+	}
+
+	laddrs, err := localAddrs(network, d.Interface)
+	if err != nil {
+		return nil, err
+	}
+	if len(laddrs) == 0 {
+		return nd.DialContext(ctx, network, addr)
+	}
+
+	for _, laddr := range laddrs {
+		nd.LocalAddr = laddr
+		var conn net.Conn
+		conn, err = nd.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// localAddrs returns the candidate local addresses for ifceName, which is
+// either an IP address or an interface name. An interface that is bound by
+// name at the socket level (see bindToDevice) yields no addresses.
+func localAddrs(network, ifceName string) ([]net.Addr, error) {
+	var ips []net.IP
+	if ip := net.ParseIP(ifceName); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		if bindToDevice {
+			return nil, nil
+		}
+
+		ifce, err := net.InterfaceByName(ifceName)
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := ifce.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("interface %s has no address", ifceName)
+		}
+	}
+
+	var laddrs []net.Addr
+	for _, ip := range ips {
+		switch network {
+		case "udp", "udp4", "udp6":
+			laddrs = append(laddrs, &net.UDPAddr{IP: ip})
+		default:
+			laddrs = append(laddrs, &net.TCPAddr{IP: ip})
+		}
+	}
+	return laddrs, nil
+}
@@ -0,0 +1,110 @@
+package net
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+)
+
+// bindToDevice reports whether interface names are bound with
+// SO_BINDTODEVICE rather than by local address.
+const bindToDevice = true
+
+func (d *NetDialer) control(network, address string, c syscall.RawConn) error {
+	var cerr error
+	err := c.Control(func(fd uintptr) {
+		if d.Interface != "" && net.ParseIP(d.Interface) == nil {
+			if err := syscall.BindToDevice(int(fd), d.Interface); err != nil {
+				cerr = fmt.Errorf("bind to device %s: %w", d.Interface, err)
+				return
+			}
+		}
+		if d.Mark != 0 {
+			if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, d.Mark); err != nil {
+				cerr = fmt.Errorf("set mark %d: %w", d.Mark, err)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return cerr
+}
+
+// withNetns runs fn on a dedicated OS thread switched into the network
+// namespace netns. If the thread cannot be switched back it is left locked,
+// so the runtime discards it when the goroutine exits.
+func withNetns(netns string, fn func() error) error {
+	path := netns
+	if !filepath.IsAbs(path) {
+		path = filepath.Join("/var/run/netns", netns)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		cur, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", syscall.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			errc <- err
+			return
+		}
+		defer cur.Close()
+
+		target, err := os.Open(path)
+		if err != nil {
+			runtime.UnlockOSThread()
+			errc <- fmt.Errorf("netns %s: %w", netns, err)
+			return
+		}
+		defer target.Close()
+
+		if err := setns(target.Fd()); err != nil {
+			runtime.UnlockOSThread()
+			errc <- fmt.Errorf("setns %s: %w", netns, err)
+			return
+		}
+
+		err = fn()
+		if setns(cur.Fd()) == nil {
+			runtime.UnlockOSThread()
+		}
+		errc <- err
+	}()
+
+	return <-errc
+}
+
+// sysSetns holds the setns(2) syscall numbers, which the syscall package
+// does not export.
+var sysSetns = map[string]uintptr{
+	"386":      346,
+	"amd64":    308,
+	"arm":      375,
+	"arm64":    268,
+	"loong64":  268,
+	"mips":     4344,
+	"mipsle":   4344,
+	"mips64":   5303,
+	"mips64le": 5303,
+	"ppc64":    350,
+	"ppc64le":  350,
+	"riscv64":  268,
+	"s390x":    339,
+}
+
+func setns(fd uintptr) error {
+	trap, ok := sysSetns[runtime.GOARCH]
+	if !ok {
+		return syscall.ENOSYS
+	}
+	if _, _, e := syscall.RawSyscall(trap, fd, syscall.CLONE_NEWNET, 0); e != 0 {
+		return e
+	}
+	return nil
+}
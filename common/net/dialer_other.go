@@ -0,0 +1,20 @@
+//go:build !linux
+
+package net
+
+import (
+	"errors"
+	"syscall"
+)
+
+// bindToDevice reports whether interface names are bound with
+// SO_BINDTODEVICE rather than by local address.
+const bindToDevice = false
+
+func (d *NetDialer) control(network, address string, c syscall.RawConn) error {
+	return nil
+}
+
+func withNetns(netns string, fn func() error) error {
+	return errors.New("netns is not supported on this platform")
+}
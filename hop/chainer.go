@@ -0,0 +1,56 @@
+package hop
+
+import (
+	"context"
+
+	"github.com/go-gost/core/bypass"
+	"github.com/go-gost/core/chain"
+)
+
+type chainer struct {
+	hops []Hop
+}
+
+// NewChainer creates a chain.Chainer that builds each Route by selecting one
+// node from every hop, in order. A hop whose selected node has a Bypass
+// containing the target is skipped, so a route whose hops are all bypassed,
+// like the route of a chainer without hops, reaches the target directly.
+// Route returns nil if a hop has no available node.
+func NewChainer(hops ...Hop) chain.Chainer {
+	return &chainer{
+		hops: hops,
+	}
+}
+
+func (c *chainer) Route(ctx context.Context, network, address string, opts ...chain.RouteOption) chain.Route {
+	var options chain.RouteOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	var nodes []*chain.Node
+	for _, h := range c.hops {
+		if h == nil {
+			continue
+		}
+
+		node := h.Select(ctx,
+			NetworkSelectOption(network),
+			AddrSelectOption(address),
+			HostSelectOption(options.Host),
+		)
+		if node == nil {
+			return nil
+		}
+
+		if bp := node.Options().Bypass; bp != nil &&
+			bp.Contains(ctx, network, address, bypass.WithHostOption(options.Host)) {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+
+	return chain.NewRoute(nodes...)
+}
@@ -0,0 +1,140 @@
+package hop
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/selector"
+)
+
+const (
+	// DefaultProbeStaleIntervals is the number of probe intervals after
+	// which ProbeFilter considers a probe result stale.
+	DefaultProbeStaleIntervals = 3
+)
+
+type probeFilter[T any] struct {
+	maxAge   time.Duration
+	failOpen bool
+}
+
+// ProbeFilter returns a selector.Filter that excludes items whose latest
+// probe result (see chain.ProbeResultReader) is unsuccessful or older than
+// staleIntervals probe intervals. Items that have not been probed yet are
+// kept. If failOpen is true and every item would be excluded, the items
+// are returned unfiltered rather than leaving nothing to select.
+func ProbeFilter[T any](interval time.Duration, staleIntervals int, failOpen bool) selector.Filter[T] {
+	if interval <= 0 {
+		interval = chain.DefaultProbeInterval
+	}
+	if staleIntervals <= 0 {
+		staleIntervals = DefaultProbeStaleIntervals
+	}
+
+	return &probeFilter[T]{
+		maxAge:   interval * time.Duration(staleIntervals),
+		failOpen: failOpen,
+	}
+}
+
+func (f *probeFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	var l []T
+	for _, v := range vs {
+		if f.healthy(v) {
+			l = append(l, v)
+		}
+	}
+
+	if len(l) == 0 && f.failOpen {
+		return vs
+	}
+	return l
+}
+
+func (f *probeFilter[T]) healthy(v T) bool {
+	r, _ := any(v).(chain.ProbeResultReader)
+	if r == nil {
+		return true
+	}
+	result := r.ProbeResult()
+	if result == nil {
+		return true
+	}
+	return result.Success && time.Since(result.Timestamp) <= f.maxAge
+}
+
+const (
+	// DefaultPriorityThreshold is the healthy fraction of a priority tier
+	// below which PriorityFilter spills over to the next lower tier.
+	DefaultPriorityThreshold = 0.7
+)
+
+type priorityFilter[T any] struct {
+	threshold float64
+	healthy   []selector.Filter[T]
+}
+
+// PriorityFilter returns a selector.Filter for primary/backup setups. It
+// groups the items by chain.NodeOptions.Priority, highest first, and keeps
+// the healthy items of the highest tier. When the healthy fraction of a tier
+// is below threshold, the healthy items of the next lower tier are kept as
+// well, and so on down the tiers.
+//
+// Health is decided by applying the healthy filters (e.g. FailFilter and
+// ProbeFilter) to each tier; without filters every item is healthy. Items
+// that do not expose NodeOptions have priority 0.
+func PriorityFilter[T any](threshold float64, healthy ...selector.Filter[T]) selector.Filter[T] {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultPriorityThreshold
+	}
+
+	return &priorityFilter[T]{
+		threshold: threshold,
+		healthy:   healthy,
+	}
+}
+
+func (f *priorityFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	if len(vs) == 0 {
+		return nil
+	}
+
+	tiers := map[int][]T{}
+	var priorities []int
+	for _, v := range vs {
+		p := priorityOf(v)
+		if _, ok := tiers[p]; !ok {
+			priorities = append(priorities, p)
+		}
+		tiers[p] = append(tiers[p], v)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(priorities)))
+
+	var l []T
+	for _, p := range priorities {
+		tier := tiers[p]
+		available := tier
+		for _, filter := range f.healthy {
+			if filter != nil {
+				available = filter.Filter(ctx, available...)
+			}
+		}
+		l = append(l, available...)
+
+		if float64(len(available)) >= f.threshold*float64(len(tier)) {
+			break
+		}
+	}
+	return l
+}
+
+func priorityOf(v any) int {
+	if o, _ := v.(interface{ Options() *chain.NodeOptions }); o != nil {
+		if opts := o.Options(); opts != nil {
+			return opts.Priority
+		}
+	}
+	return 0
+}
@@ -7,8 +7,14 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/routing"
+	"github.com/go-gost/core/selector"
 )
 
 // SelectOptions holds the runtime context used to select a node.
@@ -40,6 +46,15 @@ type SelectOptions struct {
 	Body []byte
 }
 
+// HashKey returns the key used by hash-based strategies (see
+// selector.HashStrategy): the client IP if known, otherwise the target host.
+func (o *SelectOptions) HashKey() string {
+	if o.ClientIP != nil {
+		return o.ClientIP.String()
+	}
+	return o.Host
+}
+
 // SelectOption is a functional option for configuring SelectOptions.
 type SelectOption func(*SelectOptions)
 
@@ -128,3 +143,406 @@ type Hop interface {
 type NodeList interface {
 	Nodes() []*chain.Node
 }
+
+// NodeSet is implemented by hops whose nodes can be changed at runtime, e.g.
+// from service discovery results or a watched configuration file. Nodes are
+// identified by Name and Addr: a node replacing one with the same identity
+// adopts its health state (see chain.Node.AdoptState), and removed nodes are
+// closed, stopping their probes. Each change is applied atomically with
+// respect to Select and Nodes.
+type NodeSet interface {
+	NodeList
+	// Add adds nodes, replacing the nodes with the same identity.
+	Add(nodes ...*chain.Node)
+	// Remove removes the nodes with the given names.
+	Remove(names ...string)
+	// SetNodes replaces all the nodes.
+	SetNodes(nodes ...*chain.Node)
+	// Watch returns a channel receiving an Event for each change of the
+	// node set, in order and without loss, until ctx is done.
+	Watch(ctx context.Context) <-chan Event
+}
+
+// Event describes a change of the nodes of a NodeSet.
+type Event struct {
+	// Added holds the nodes with a new identity.
+	Added []*chain.Node
+	// Updated holds the nodes that replaced a node with the same identity.
+	Updated []*chain.Node
+	// Removed holds the nodes no longer in the set.
+	Removed []*chain.Node
+}
+
+// Options holds the initialization parameters for a Hop created by NewHop.
+type Options struct {
+	// Name is the hop name.
+	Name string
+	// Nodes is the group of nodes to select from.
+	Nodes []*chain.Node
+	// Selector picks a node among the eligible ones.
+	Selector selector.Selector[*chain.Node]
+	// Logger is the logger for hop operations.
+	Logger logger.Logger
+}
+
+// Option is a functional option for configuring Options.
+type Option func(opts *Options)
+
+// NameOption sets the hop name.
+func NameOption(name string) Option {
+	return func(opts *Options) {
+		opts.Name = name
+	}
+}
+
+// NodeOption sets the nodes of the hop.
+func NodeOption(nodes ...*chain.Node) Option {
+	return func(opts *Options) {
+		opts.Nodes = nodes
+	}
+}
+
+// SelectorOption sets the node Selector.
+func SelectorOption(s selector.Selector[*chain.Node]) Option {
+	return func(opts *Options) {
+		opts.Selector = s
+	}
+}
+
+// LoggerOption sets the logger.
+func LoggerOption(logger logger.Logger) Option {
+	return func(opts *Options) {
+		opts.Logger = logger
+	}
+}
+
+type hop struct {
+	options Options
+	nodes   atomic.Pointer[[]*chain.Node]
+	mu      sync.Mutex
+	watches map[*watcher]struct{}
+}
+
+// NewHop creates a Hop that routes by the nodes' L7 settings. Select narrows
+// the nodes to those whose NodeFilterSettings (protocol, host pattern and
+// longest matching path prefix) and routing Matcher accept the request, then
+// picks one of them with the Selector. The default Selector applies
+// selector.FailFilter and selector.RoundRobinStrategy. The hop also
+// implements NodeList and NodeSet.
+func NewHop(opts ...Option) Hop {
+	var options Options
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.Selector == nil {
+		options.Selector = selector.NewSelector(
+			selector.RoundRobinStrategy[*chain.Node](),
+			selector.FailFilter[*chain.Node](selector.DefaultMaxFails, selector.DefaultFailTimeout, selector.DefaultMaxFailTimeout),
+		)
+	}
+	options.Logger = logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind": "hop",
+		"hop":  options.Name,
+	})
+
+	h := &hop{
+		options: options,
+		watches: make(map[*watcher]struct{}),
+	}
+	h.nodes.Store(&options.Nodes)
+
+	return h
+}
+
+func (h *hop) Nodes() []*chain.Node {
+	return *h.nodes.Load()
+}
+
+func (h *hop) Add(nodes ...*chain.Node) {
+	h.update(func(current []*chain.Node) []*chain.Node {
+		l := make([]*chain.Node, 0, len(current)+len(nodes))
+		for _, node := range current {
+			if node != nil && indexNode(nodes, node) < 0 {
+				l = append(l, node)
+			}
+		}
+		for _, node := range nodes {
+			if node != nil && indexNode(l, node) < 0 {
+				l = append(l, node)
+			}
+		}
+		return l
+	})
+}
+
+func (h *hop) Remove(names ...string) {
+	h.update(func(current []*chain.Node) []*chain.Node {
+		var l []*chain.Node
+	next:
+		for _, node := range current {
+			if node == nil {
+				continue
+			}
+			for _, name := range names {
+				if node.Name == name {
+					continue next
+				}
+			}
+			l = append(l, node)
+		}
+		return l
+	})
+}
+
+func (h *hop) SetNodes(nodes ...*chain.Node) {
+	h.update(func([]*chain.Node) []*chain.Node {
+		var l []*chain.Node
+		for _, node := range nodes {
+			if node != nil && indexNode(l, node) < 0 {
+				l = append(l, node)
+			}
+		}
+		return l
+	})
+}
+
+func (h *hop) Watch(ctx context.Context) <-chan Event {
+	w := &watcher{
+		ch:     make(chan Event),
+		signal: make(chan struct{}, 1),
+	}
+
+	h.mu.Lock()
+	h.watches[w] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		w.run(ctx)
+
+		h.mu.Lock()
+		delete(h.watches, w)
+		h.mu.Unlock()
+	}()
+
+	return w.ch
+}
+
+// update replaces the nodes with the result of fn, carrying the health
+// state of replaced nodes over, closing removed nodes and notifying the
+// watchers.
+func (h *hop) update(fn func(current []*chain.Node) []*chain.Node) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	current := h.Nodes()
+	nodes := fn(current)
+
+	var ev Event
+	for _, node := range nodes {
+		i := indexNode(current, node)
+		switch {
+		case i < 0:
+			ev.Added = append(ev.Added, node)
+		case current[i] != node:
+			node.AdoptState(current[i])
+			ev.Updated = append(ev.Updated, node)
+		}
+	}
+	for _, node := range current {
+		if node != nil && indexNode(nodes, node) < 0 {
+			ev.Removed = append(ev.Removed, node)
+		}
+	}
+
+	h.nodes.Store(&nodes)
+
+	for _, node := range ev.Updated {
+		current[indexNode(current, node)].Close()
+	}
+	for _, node := range ev.Removed {
+		node.Close()
+	}
+
+	if len(ev.Added)+len(ev.Updated)+len(ev.Removed) == 0 {
+		return
+	}
+	h.options.Logger.Debugf("nodes changed: %d added, %d updated, %d removed",
+		len(ev.Added), len(ev.Updated), len(ev.Removed))
+
+	for w := range h.watches {
+		w.push(ev)
+	}
+}
+
+// watcher delivers the events of a Watch through an unbounded queue, so
+// that a slow receiver does not block changes of the nodes.
+type watcher struct {
+	ch     chan Event
+	mu     sync.Mutex
+	queue  []Event
+	signal chan struct{}
+}
+
+func (w *watcher) push(ev Event) {
+	w.mu.Lock()
+	w.queue = append(w.queue, ev)
+	w.mu.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher) run(ctx context.Context) {
+	defer close(w.ch)
+
+	for {
+		select {
+		case <-w.signal:
+		case <-ctx.Done():
+			return
+		}
+
+		for {
+			w.mu.Lock()
+			if len(w.queue) == 0 {
+				w.mu.Unlock()
+				break
+			}
+			ev := w.queue[0]
+			w.queue = w.queue[1:]
+			w.mu.Unlock()
+
+			select {
+			case w.ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// indexNode returns the index of the node in nodes with the same Name and
+// Addr as node, or -1.
+func indexNode(nodes []*chain.Node, node *chain.Node) int {
+	for i, n := range nodes {
+		if n != nil && n.Name == node.Name && n.Addr == node.Addr {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *hop) Select(ctx context.Context, opts ...SelectOption) *chain.Node {
+	var options SelectOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	nodes := FilterNodes(&options, h.Nodes()...)
+	if len(nodes) == 0 {
+		h.options.Logger.Debugf("no node matches %s/%s (host=%s, path=%s)", options.Addr, options.Network, options.Host, options.Path)
+		return nil
+	}
+
+	ctx = selector.ContextWithHash(ctx, options.HashKey())
+	return h.options.Selector.Select(ctx, nodes...)
+}
+
+// FilterNodes returns the nodes eligible for a request described by
+// options: nodes whose NodeFilterSettings protocol and host pattern accept
+// the request, whose path filter is the longest prefix of the request path
+// (nodes without path filter are used only when no path filter matches),
+// and whose routing Matcher, if any, matches the request. Each Matcher sees
+// at most the node's MatcherBodySize bytes of the request body.
+func FilterNodes(options *SelectOptions, nodes ...*chain.Node) []*chain.Node {
+	var l []*chain.Node
+	for _, node := range nodes {
+		if node == nil {
+			continue
+		}
+		if filter := node.Options().Filter; filter != nil {
+			if filter.Protocol != "" && !strings.EqualFold(filter.Protocol, options.Protocol) {
+				continue
+			}
+			if filter.Host != "" && !routing.MatchHost(filter.Host, options.Host) {
+				continue
+			}
+		}
+		l = append(l, node)
+	}
+
+	l = filterByPath(options.Path, l)
+
+	var nodesMatched []*chain.Node
+	var req *routing.Request
+	for _, node := range l {
+		matcher := node.Options().Matcher
+		if matcher == nil {
+			nodesMatched = append(nodesMatched, node)
+			continue
+		}
+
+		if req == nil {
+			req = &routing.Request{
+				ClientIP: options.ClientIP,
+				Host:     options.Host,
+				Network:  options.Network,
+				Protocol: options.Protocol,
+				Method:   options.Method,
+				Path:     options.Path,
+				Query:    options.Query,
+				Header:   options.Header,
+			}
+		}
+		req.Body = nil
+		if n := node.Options().MatcherBodySize; n > 0 {
+			req.Body = options.Body
+			if len(req.Body) > n {
+				req.Body = req.Body[:n]
+			}
+		}
+		if matcher.Match(req) {
+			nodesMatched = append(nodesMatched, node)
+		}
+	}
+	return nodesMatched
+}
+
+// filterByPath keeps the nodes whose path filter is the longest prefix of
+// path, or the nodes without path filter if no path filter matches.
+func filterByPath(path string, nodes []*chain.Node) []*chain.Node {
+	var plain, matched []*chain.Node
+	longest := -1
+	for _, node := range nodes {
+		var prefix string
+		if filter := node.Options().Filter; filter != nil {
+			prefix = filter.Path
+		}
+		if prefix == "" {
+			plain = append(plain, node)
+			continue
+		}
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		switch {
+		case len(prefix) > longest:
+			longest = len(prefix)
+			matched = append(matched[:0], node)
+		case len(prefix) == longest:
+			matched = append(matched, node)
+		}
+	}
+
+	if len(matched) > 0 {
+		return matched
+	}
+	return plain
+}
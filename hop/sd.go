@@ -0,0 +1,328 @@
+package hop
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/logger"
+	"github.com/go-gost/core/sd"
+	"github.com/go-gost/core/selector"
+)
+
+const (
+	// DefaultSDInterval is the interval between service discovery queries
+	// when none is configured.
+	DefaultSDInterval = 10 * time.Second
+	// DefaultSDTimeout is the timeout of a service discovery query when none
+	// is configured.
+	DefaultSDTimeout = 5 * time.Second
+)
+
+// SDOptions holds the initialization parameters for a Hop created by
+// NewSDHop.
+type SDOptions struct {
+	// Name is the hop name.
+	Name string
+	// Interval is the interval between queries of the service.
+	Interval time.Duration
+	// Timeout is the timeout of a query.
+	Timeout time.Duration
+	// Transport is the template Transporter of the nodes. Each node gets a
+	// copy with its address set to the service address.
+	Transport chain.Transporter
+	// NodeOptions are applied to each node, e.g. to set a Bypass.
+	NodeOptions []chain.NodeOption
+	// Selector picks a node among the eligible ones.
+	Selector selector.Selector[*chain.Node]
+	// Logger is the logger for hop operations.
+	Logger logger.Logger
+}
+
+// SDOption is a functional option for configuring SDOptions.
+type SDOption func(opts *SDOptions)
+
+// NameSDOption sets the hop name.
+func NameSDOption(name string) SDOption {
+	return func(opts *SDOptions) {
+		opts.Name = name
+	}
+}
+
+// IntervalSDOption sets the interval between queries.
+func IntervalSDOption(d time.Duration) SDOption {
+	return func(opts *SDOptions) {
+		opts.Interval = d
+	}
+}
+
+// TimeoutSDOption sets the timeout of a query.
+func TimeoutSDOption(d time.Duration) SDOption {
+	return func(opts *SDOptions) {
+		opts.Timeout = d
+	}
+}
+
+// TransportSDOption sets the template Transporter of the nodes.
+func TransportSDOption(tr chain.Transporter) SDOption {
+	return func(opts *SDOptions) {
+		opts.Transport = tr
+	}
+}
+
+// NodeOptionsSDOption sets the options applied to each node.
+func NodeOptionsSDOption(opts ...chain.NodeOption) SDOption {
+	return func(o *SDOptions) {
+		o.NodeOptions = opts
+	}
+}
+
+// SelectorSDOption sets the node Selector.
+func SelectorSDOption(s selector.Selector[*chain.Node]) SDOption {
+	return func(opts *SDOptions) {
+		opts.Selector = s
+	}
+}
+
+// LoggerSDOption sets the logger.
+func LoggerSDOption(logger logger.Logger) SDOption {
+	return func(opts *SDOptions) {
+		opts.Logger = logger
+	}
+}
+
+type sdHop struct {
+	hop     *hop
+	sd      sd.SD
+	service string
+	options SDOptions
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewSDHop creates a Hop whose nodes are the instances of the named service
+// in sd. The service is queried once before NewSDHop returns; it is then
+// followed with Watch if sd implements sd.Watcher, or queried every
+// Interval otherwise. Each instance becomes a node named by its ID (or its
+// address if the ID is empty), with the instance's network and address and
+// a copy of the template Transporter. Instances that are still present keep
+// their node, and so its health state; if a query fails the nodes are left
+// as they are.
+//
+// Select behaves as for NewHop. The hop also implements NodeList, the Watch
+// method of NodeSet, and io.Closer: Close stops querying sd.
+func NewSDHop(sd sd.SD, service string, opts ...SDOption) Hop {
+	var options SDOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.Interval <= 0 {
+		options.Interval = DefaultSDInterval
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultSDTimeout
+	}
+	if options.Name == "" {
+		options.Name = service
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &sdHop{
+		hop: NewHop(
+			NameOption(options.Name),
+			SelectorOption(options.Selector),
+			LoggerOption(options.Logger),
+		).(*hop),
+		sd:      sd,
+		service: service,
+		options: options,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	h.refresh(ctx)
+	go h.run(ctx)
+
+	return h
+}
+
+func (h *sdHop) Select(ctx context.Context, opts ...SelectOption) *chain.Node {
+	return h.hop.Select(ctx, opts...)
+}
+
+func (h *sdHop) Nodes() []*chain.Node {
+	return h.hop.Nodes()
+}
+
+func (h *sdHop) Watch(ctx context.Context) <-chan Event {
+	return h.hop.Watch(ctx)
+}
+
+// Close stops querying the service discovery and closes the nodes.
+func (h *sdHop) Close() error {
+	h.once.Do(func() {
+		h.cancel()
+		<-h.done
+		h.hop.SetNodes()
+	})
+	return nil
+}
+
+func (h *sdHop) run(ctx context.Context) {
+	defer close(h.done)
+
+	if w, ok := h.sd.(sd.Watcher); ok {
+		h.watch(ctx, w)
+		return
+	}
+
+	ticker := time.NewTicker(h.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.refresh(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *sdHop) refresh(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, h.options.Timeout)
+	defer cancel()
+
+	services, err := h.sd.Get(ctx, h.service)
+	if err != nil {
+		if ctx.Err() != context.Canceled {
+			h.hop.options.Logger.Errorf("sd get %s: %v", h.service, err)
+		}
+		return
+	}
+	h.setServices(services)
+}
+
+// watch follows the service with w, watching again after Interval when a
+// watch ends or fails.
+func (h *sdHop) watch(ctx context.Context, w sd.Watcher) {
+	for {
+		ch, err := w.Watch(ctx, h.service)
+		if err != nil {
+			h.hop.options.Logger.Errorf("sd watch %s: %v", h.service, err)
+		} else {
+			h.follow(ch)
+		}
+
+		select {
+		case <-time.After(h.options.Interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// follow applies the events of a watch until its channel is closed.
+func (h *sdHop) follow(ch <-chan sd.Event) {
+	services := make(map[string]*sd.Service)
+	for _, node := range h.hop.Nodes() {
+		services[node.Name] = &sd.Service{
+			ID:      node.Name,
+			Network: node.Options().Network,
+			Address: node.Addr,
+		}
+	}
+
+	for ev := range ch {
+		switch ev.Type {
+		case sd.EventResync:
+			clear(services)
+			for _, service := range ev.Services {
+				if service != nil {
+					services[serviceID(service)] = service
+				}
+			}
+		case sd.EventAdd, sd.EventUpdate:
+			if ev.Service != nil {
+				services[serviceID(ev.Service)] = ev.Service
+			}
+		case sd.EventRemove:
+			if ev.Service != nil {
+				delete(services, serviceID(ev.Service))
+			}
+		default:
+			continue
+		}
+
+		ids := make([]string, 0, len(services))
+		for id := range services {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		l := make([]*sd.Service, 0, len(ids))
+		for _, id := range ids {
+			l = append(l, services[id])
+		}
+		h.setServices(l)
+	}
+}
+
+// setServices updates the nodes to the given service instances, keeping the
+// nodes of the instances that did not change.
+func (h *sdHop) setServices(services []*sd.Service) {
+	current := h.hop.Nodes()
+
+	var nodes []*chain.Node
+	for _, service := range services {
+		if service == nil || service.Address == "" {
+			continue
+		}
+		name := serviceID(service)
+		if node := findNode(current, name, service); node != nil {
+			nodes = append(nodes, node)
+			continue
+		}
+		nodes = append(nodes, h.newNode(name, service))
+	}
+
+	h.hop.SetNodes(nodes...)
+}
+
+func (h *sdHop) newNode(name string, service *sd.Service) *chain.Node {
+	opts := append([]chain.NodeOption{}, h.options.NodeOptions...)
+	opts = append(opts, chain.NetworkNodeOption(service.Network))
+	if h.options.Transport != nil {
+		tr := h.options.Transport.Copy()
+		tr.Options().Addr = service.Address
+		opts = append(opts, chain.TransportNodeOption(tr))
+	}
+	return chain.NewNode(name, service.Address, opts...)
+}
+
+// findNode returns the node in nodes created for the named service instance,
+// or nil.
+func findNode(nodes []*chain.Node, name string, service *sd.Service) *chain.Node {
+	for _, node := range nodes {
+		if node.Name == name && node.Addr == service.Address &&
+			node.Options().Network == service.Network {
+			return node
+		}
+	}
+	return nil
+}
+
+// serviceID returns the node name of a service instance: its ID, or its
+// address if the ID is empty.
+func serviceID(service *sd.Service) string {
+	if service.ID != "" {
+		return service.ID
+	}
+	return service.Address
+}
@@ -0,0 +1,49 @@
+package hop
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/go-gost/core/chain"
+	"github.com/go-gost/core/selector"
+)
+
+type leastLatencyStrategy[T any] struct {
+	counter uint64
+}
+
+// LeastLatencyStrategy returns a selector.Strategy that selects the item
+// with the lowest latency in its latest successful probe result (see
+// chain.ProbeResultReader). Items that have no successful probe result are
+// only chosen when none has; they are then selected in round-robin order.
+func LeastLatencyStrategy[T any]() selector.Strategy[T] {
+	return &leastLatencyStrategy[T]{}
+}
+
+func (s *leastLatencyStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	best := -1
+	var bestResult *chain.ProbeResult
+	for i := range vs {
+		r, _ := any(vs[i]).(chain.ProbeResultReader)
+		if r == nil {
+			continue
+		}
+		result := r.ProbeResult()
+		if result == nil || !result.Success {
+			continue
+		}
+		if best < 0 || result.Latency < bestResult.Latency {
+			best, bestResult = i, result
+		}
+	}
+	if best >= 0 {
+		return vs[best]
+	}
+
+	n := atomic.AddUint64(&s.counter, 1) - 1
+	return vs[n%uint64(len(vs))]
+}
@@ -182,3 +182,60 @@ func (l *loggerGroup) IsLevelEnabled(level LogLevel) bool {
 	}
 	return false
 }
+
+// OrDefault returns logger, falling back to the default Logger and then to
+// Nop when it is nil.
+func OrDefault(logger Logger) Logger {
+	if logger != nil {
+		return logger
+	}
+	if logger = Default(); logger != nil {
+		return logger
+	}
+	return Nop()
+}
+
+// nopLogger is a Logger that discards all log entries.
+type nopLogger struct{}
+
+// Nop returns a Logger that discards all log entries. It is useful as a
+// fallback when neither an explicit nor a default logger is configured.
+func Nop() Logger {
+	return &nopLogger{}
+}
+
+func (l *nopLogger) WithFields(m map[string]any) Logger {
+	return l
+}
+
+func (l *nopLogger) Trace(args ...any) {}
+
+func (l *nopLogger) Tracef(format string, args ...any) {}
+
+func (l *nopLogger) Debug(args ...any) {}
+
+func (l *nopLogger) Debugf(format string, args ...any) {}
+
+func (l *nopLogger) Info(args ...any) {}
+
+func (l *nopLogger) Infof(format string, args ...any) {}
+
+func (l *nopLogger) Warn(args ...any) {}
+
+func (l *nopLogger) Warnf(format string, args ...any) {}
+
+func (l *nopLogger) Error(args ...any) {}
+
+func (l *nopLogger) Errorf(format string, args ...any) {}
+
+func (l *nopLogger) Fatal(args ...any) {}
+
+func (l *nopLogger) Fatalf(format string, args ...any) {}
+
+func (l *nopLogger) GetLevel() LogLevel {
+	return InfoLevel
+}
+
+func (l *nopLogger) IsLevelEnabled(level LogLevel) bool {
+	return false
+}
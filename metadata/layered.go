@@ -0,0 +1,189 @@
+package metadata
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Layer is a scope of a LayeredMetadata.
+type Layer string
+
+// Layers of a LayeredMetadata, from the highest precedence to the lowest.
+const (
+	LayerRequest Layer = "request"
+	LayerNode    Layer = "node"
+	LayerService Layer = "service"
+	LayerGlobal  Layer = "global"
+)
+
+// LayeredMetadata is a Metadata resolving each key from the first layer
+// that has it, in the order request, node, service, global. Nil layers are
+// skipped. It records which layer a value comes from, for debugging the
+// effective configuration of a request.
+type LayeredMetadata struct {
+	Request Metadata
+	Node    Metadata
+	Service Metadata
+	Global  Metadata
+}
+
+// LayeredValue is a value of a LayeredMetadata and its origin.
+type LayeredValue struct {
+	Key   string
+	Value any
+	// Layer is the layer the value comes from.
+	Layer Layer
+	// Shadowed lists the lower layers that also have the key.
+	Shadowed []Layer
+}
+
+// WithLayer returns a copy of the LayeredMetadata with the layer set to md,
+// e.g. to add the request layer to the layers of a service.
+func (m *LayeredMetadata) WithLayer(layer Layer, md Metadata) *LayeredMetadata {
+	c := &LayeredMetadata{}
+	if m != nil {
+		*c = *m
+	}
+	switch layer {
+	case LayerRequest:
+		c.Request = md
+	case LayerNode:
+		c.Node = md
+	case LayerService:
+		c.Service = md
+	case LayerGlobal:
+		c.Global = md
+	}
+	return c
+}
+
+// IsExists reports whether a key is present in any layer.
+func (m *LayeredMetadata) IsExists(key string) bool {
+	_, _, ok := m.Lookup(key)
+	return ok
+}
+
+// Get returns the value of key from the highest layer that has it, or nil.
+func (m *LayeredMetadata) Get(key string) any {
+	v, _, _ := m.Lookup(key)
+	return v
+}
+
+// Set stores the value in the request layer, creating it as a MapMetadata
+// if needed, so that it overrides the lower layers for this request only.
+func (m *LayeredMetadata) Set(key string, value any) {
+	if mm, ok := m.Request.(MapMetadata); m.Request == nil || ok && mm == nil {
+		m.Request = MapMetadata{}
+	}
+	m.Request.Set(key, value)
+}
+
+// Lookup returns the value of key and the layer it comes from.
+func (m *LayeredMetadata) Lookup(key string) (any, Layer, bool) {
+	for _, l := range m.layers() {
+		if l.md.IsExists(key) {
+			return l.md.Get(key), l.layer, true
+		}
+	}
+	return nil, "", false
+}
+
+// Keys returns the keys of the layers implementing KeyLister, lowercased
+// like the keys of a MapMetadata, in lexical order and without duplicates.
+// Implements KeyLister.
+func (m *LayeredMetadata) Keys() []string {
+	return sortedKeys(m.keySpellings())
+}
+
+// Values returns the effective values of the keys listed by Keys, with
+// their origin, in key order.
+func (m *LayeredMetadata) Values() []LayeredValue {
+	spellings := m.keySpellings()
+	keys := sortedKeys(spellings)
+	values := make([]LayeredValue, 0, len(keys))
+	for _, key := range keys {
+		v := LayeredValue{Key: key}
+		for _, l := range m.layers() {
+			spelling, ok := lookupSpelling(l.md, spellings[key])
+			if !ok {
+				continue
+			}
+			if v.Layer == "" {
+				v.Value = l.md.Get(spelling)
+				v.Layer = l.layer
+			} else {
+				v.Shadowed = append(v.Shadowed, l.layer)
+			}
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// keySpellings maps the lowercased keys of the layers implementing
+// KeyLister to the spellings of the key found in the layers.
+func (m *LayeredMetadata) keySpellings() map[string][]string {
+	spellings := make(map[string][]string)
+	for _, l := range m.layers() {
+		lister, ok := l.md.(KeyLister)
+		if !ok {
+			continue
+		}
+		for _, key := range lister.Keys() {
+			lower := strings.ToLower(key)
+			if !slices.Contains(spellings[lower], key) {
+				spellings[lower] = append(spellings[lower], key)
+			}
+		}
+	}
+	return spellings
+}
+
+func sortedKeys(spellings map[string][]string) []string {
+	if len(spellings) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(spellings))
+	for key := range spellings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookupSpelling returns the first of the spellings of a key present in md.
+func lookupSpelling(md Metadata, spellings []string) (string, bool) {
+	for _, key := range spellings {
+		if md.IsExists(key) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+type metadataLayer struct {
+	layer Layer
+	md    Metadata
+}
+
+// layers returns the non-nil layers by precedence.
+func (m *LayeredMetadata) layers() []metadataLayer {
+	if m == nil {
+		return nil
+	}
+
+	var layers []metadataLayer
+	for _, l := range []metadataLayer{
+		{LayerRequest, m.Request},
+		{LayerNode, m.Node},
+		{LayerService, m.Service},
+		{LayerGlobal, m.Global},
+	} {
+		if l.md != nil {
+			layers = append(layers, l)
+		}
+	}
+	return layers
+}
@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"sort"
+	"strings"
+)
+
+// MapMetadata is a map-backed Metadata with case-insensitive keys. Keys are
+// stored in lower case; keys of a map converted directly to MapMetadata are
+// still found regardless of their case, at the cost of a scan.
+type MapMetadata map[string]any
+
+// NewMapMetadata creates a MapMetadata holding the entries of m.
+func NewMapMetadata(m map[string]any) MapMetadata {
+	md := make(MapMetadata, len(m))
+	for k, v := range m {
+		md[strings.ToLower(k)] = v
+	}
+	return md
+}
+
+// IsExists reports whether a key is present.
+func (m MapMetadata) IsExists(key string) bool {
+	_, ok := m.lookup(key)
+	return ok
+}
+
+// Set stores a value for the given key, replacing a value stored under the
+// same key in another case.
+func (m MapMetadata) Set(key string, value any) {
+	lower := strings.ToLower(key)
+	if _, ok := m[lower]; !ok {
+		for k := range m {
+			if strings.EqualFold(k, key) {
+				delete(m, k)
+			}
+		}
+	}
+	m[lower] = value
+}
+
+// Get retrieves the value for the given key, or nil if not present.
+func (m MapMetadata) Get(key string) any {
+	v, _ := m.lookup(key)
+	return v
+}
+
+func (m MapMetadata) lookup(key string) (any, bool) {
+	if v, ok := m[strings.ToLower(key)]; ok {
+		return v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Keys returns the keys of the MapMetadata in lexical order. Implements
+// KeyLister.
+func (m MapMetadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,302 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownKey reports a metadata key not declared by the Schema.
+	ErrUnknownKey = errors.New("unknown key")
+	// ErrTypeMismatch reports a value that cannot be converted to the
+	// declared type.
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrOutOfRange reports a value outside the declared bounds or choices.
+	ErrOutOfRange = errors.New("out of range")
+	// ErrRequired reports a missing required key.
+	ErrRequired = errors.New("required")
+)
+
+// Type is the type of a metadata value declared by a Field. Values are
+// checked with the same tolerant conversion as the Get functions, except
+// that a TypeInt value with a fractional part, e.g. 1.9 or "1.9", is
+// rejected instead of truncated.
+type Type string
+
+// Types of metadata values.
+const (
+	TypeAny      Type = "any"
+	TypeString   Type = "string"
+	TypeInt      Type = "int"
+	TypeBool     Type = "bool"
+	TypeFloat    Type = "float"
+	TypeDuration Type = "duration"
+	TypeStrings  Type = "strings"
+)
+
+// Field declares a metadata key accepted by a component.
+type Field struct {
+	// Key is the key, matched case-insensitively.
+	Key string
+	// Aliases are alternative keys for the same value.
+	Aliases []string
+	// Type is the type of the value. The empty Type is TypeAny.
+	Type Type
+	// Default is set by Schema.Apply when the key is missing.
+	Default any
+	// Required reports a missing key as an error.
+	Required bool
+	// Min and Max, if not nil, bound numeric and duration values. They are
+	// converted to the Field's Type like the value.
+	Min, Max any
+	// Enum, if not empty, lists the accepted values of a string value, or
+	// of each element of a strings value.
+	Enum []string
+}
+
+// Schema declares the metadata keys accepted by a component, so that its
+// Init method can reject misspelled keys and invalid values instead of
+// silently ignoring them:
+//
+//	var schema = metadata.Schema{
+//		Component: "dialer.tcp",
+//		Fields: []metadata.Field{
+//			{Key: "timeout", Type: metadata.TypeDuration, Default: "10s", Min: 0},
+//		},
+//	}
+//
+//	func (d *tcpDialer) Init(md metadata.Metadata) error {
+//		if err := schema.Apply(md); err != nil {
+//			return err
+//		}
+//		d.timeout = metadata.GetDuration(md, "timeout")
+//		return nil
+//	}
+type Schema struct {
+	// Component names the component in errors, e.g. "listener.tcp".
+	Component string
+	// Fields are the accepted keys.
+	Fields []Field
+	// AllowUnknown disables the check for undeclared keys.
+	AllowUnknown bool
+}
+
+// FieldError is an error of a metadata key found by Schema.Validate. It
+// wraps one of ErrUnknownKey, ErrTypeMismatch, ErrOutOfRange or ErrRequired.
+type FieldError struct {
+	Component string
+	Key       string
+	Value     any
+	Err       error
+	// Detail describes the error further, e.g. the violated bound.
+	Detail string
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	if e.Component != "" {
+		b.WriteString(e.Component)
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "metadata %q: %v", e.Key, e.Err)
+	if e.Detail != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Detail)
+	}
+	return b.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// KeyLister is implemented by Metadata that can list its keys, such as
+// MapMetadata. Schema.Validate reports unknown keys only for Metadata
+// implementing it.
+type KeyLister interface {
+	Keys() []string
+}
+
+// Validate checks md against the Schema. It returns nil, or the FieldErrors
+// found joined with errors.Join. A nil md has no keys.
+func (s *Schema) Validate(md Metadata) error {
+	var errs []error
+
+	if lister, ok := md.(KeyLister); ok && !s.AllowUnknown {
+		for _, key := range lister.Keys() {
+			if s.field(key) == nil {
+				errs = append(errs, s.error(key, md.Get(key), ErrUnknownKey, ""))
+			}
+		}
+	}
+
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		key, ok := f.lookup(md)
+		if !ok {
+			if f.Required {
+				errs = append(errs, s.error(f.Key, nil, ErrRequired, ""))
+			}
+			continue
+		}
+		if err := s.check(f, key, md.Get(key)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Apply validates md and, if it is valid, sets the Default of each missing
+// key that has one. Defaults cannot be set in a nil md, including a nil
+// MapMetadata, which is only validated.
+func (s *Schema) Apply(md Metadata) error {
+	if err := s.Validate(md); err != nil {
+		return err
+	}
+	if mm, ok := md.(MapMetadata); md == nil || ok && mm == nil {
+		return nil
+	}
+
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if _, ok := f.lookup(md); !ok && f.Default != nil {
+			md.Set(f.Key, f.Default)
+		}
+	}
+	return nil
+}
+
+func (s *Schema) field(key string) *Field {
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if strings.EqualFold(f.Key, key) {
+			return f
+		}
+		for _, alias := range f.Aliases {
+			if strings.EqualFold(alias, key) {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) check(f *Field, key string, v any) error {
+	var ok bool
+	var n, lo, hi float64
+	hasMin, hasMax := f.Min != nil, f.Max != nil
+
+	switch f.Type {
+	case TypeString:
+		var str string
+		if str, ok = toString(v); ok && len(f.Enum) > 0 && !slices.Contains(f.Enum, str) {
+			return s.error(key, v, ErrOutOfRange, "want one of "+strings.Join(f.Enum, ", "))
+		}
+	case TypeStrings:
+		var ss []string
+		if ss, ok = toStrings(v); ok && len(f.Enum) > 0 {
+			for _, str := range ss {
+				if !slices.Contains(f.Enum, str) {
+					return s.error(key, v, ErrOutOfRange, fmt.Sprintf("%q: want one of %s", str, strings.Join(f.Enum, ", ")))
+				}
+			}
+		}
+	case TypeBool:
+		_, ok = toBool(v)
+	case TypeInt:
+		var i int
+		if i, ok = toWholeInt(v); !ok {
+			if f, num := toFloat(v); num && f == math.Trunc(f) {
+				return s.error(key, v, ErrOutOfRange, "outside the range of int")
+			}
+		}
+		n = float64(i)
+		lo, hasMin = boundInt(f.Min, hasMin)
+		hi, hasMax = boundInt(f.Max, hasMax)
+	case TypeFloat:
+		n, ok = toFloat(v)
+		lo, hasMin = boundFloat(f.Min, hasMin)
+		hi, hasMax = boundFloat(f.Max, hasMax)
+	case TypeDuration:
+		var d time.Duration
+		d, ok = toDuration(v)
+		n = float64(d)
+		lo, hasMin = boundDuration(f.Min, hasMin)
+		hi, hasMax = boundDuration(f.Max, hasMax)
+	default:
+		return nil
+	}
+
+	if !ok {
+		return s.error(key, v, ErrTypeMismatch, "want "+string(f.Type))
+	}
+	if hasMin && n < lo {
+		return s.error(key, v, ErrOutOfRange, fmt.Sprintf("less than %v", f.Min))
+	}
+	if hasMax && n > hi {
+		return s.error(key, v, ErrOutOfRange, fmt.Sprintf("greater than %v", f.Max))
+	}
+	return nil
+}
+
+func (s *Schema) error(key string, v any, err error, detail string) error {
+	return &FieldError{
+		Component: s.Component,
+		Key:       key,
+		Value:     v,
+		Err:       err,
+		Detail:    detail,
+	}
+}
+
+// lookup returns the key, or the first alias, present in md.
+func (f *Field) lookup(md Metadata) (string, bool) {
+	if md == nil {
+		return "", false
+	}
+	if md.IsExists(f.Key) {
+		return f.Key, true
+	}
+	for _, alias := range f.Aliases {
+		if md.IsExists(alias) {
+			return alias, true
+		}
+	}
+	return "", false
+}
+
+func boundInt(v any, ok bool) (float64, bool) {
+	if !ok {
+		return 0, false
+	}
+	n, ok := toWholeInt(v)
+	return float64(n), ok
+}
+
+func boundFloat(v any, ok bool) (float64, bool) {
+	if !ok {
+		return 0, false
+	}
+	return toFloat(v)
+}
+
+func boundDuration(v any, ok bool) (float64, bool) {
+	if !ok {
+		return 0, false
+	}
+	d, ok := toDuration(v)
+	return float64(d), ok
+}
+
+// toWholeInt is toInt for values without a fractional part only.
+func toWholeInt(v any) (int, bool) {
+	if f, ok := toFloat(v); ok && f != math.Trunc(f) {
+		return 0, false
+	}
+	return toInt(v)
+}
@@ -0,0 +1,235 @@
+package metadata
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Get functions return the value of the first of keys present in md,
+// converted to the requested type. Conversions are tolerant: numbers may be
+// given as any integer or float type or as strings, booleans as numbers or
+// strings, and so on. A missing key, a nil md or a value that cannot be
+// converted yields the zero value.
+
+// GetString returns the value as a string. Numbers and booleans are
+// formatted.
+func GetString(md Metadata, keys ...string) string {
+	s, _ := toString(get(md, keys...))
+	return s
+}
+
+// GetInt returns the value as an int. Floats are truncated, strings are
+// parsed as integers or floats, and booleans are 1 or 0. Values outside the
+// range of int cannot be converted.
+func GetInt(md Metadata, keys ...string) int {
+	n, _ := toInt(get(md, keys...))
+	return n
+}
+
+// GetBool returns the value as a bool. Strings are parsed with
+// strconv.ParseBool, and numbers are true if not zero.
+func GetBool(md Metadata, keys ...string) bool {
+	b, _ := toBool(get(md, keys...))
+	return b
+}
+
+// GetFloat returns the value as a float64. Strings are parsed.
+func GetFloat(md Metadata, keys ...string) float64 {
+	f, _ := toFloat(get(md, keys...))
+	return f
+}
+
+// GetDuration returns the value as a time.Duration. Strings are parsed with
+// time.ParseDuration, and numbers, including numeric strings, are seconds.
+func GetDuration(md Metadata, keys ...string) time.Duration {
+	d, _ := toDuration(get(md, keys...))
+	return d
+}
+
+// GetStrings returns the value as a []string. A string is split at commas
+// and each element trimmed; elements of a []any are converted as by
+// GetString.
+func GetStrings(md Metadata, keys ...string) []string {
+	ss, _ := toStrings(get(md, keys...))
+	return ss
+}
+
+func get(md Metadata, keys ...string) any {
+	if md == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if md.IsExists(key) {
+			return md.Get(key)
+		}
+	}
+	return nil
+}
+
+// The to functions convert v as described for the Get functions, reporting
+// whether v could be converted.
+
+func toString(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case fmt.Stringer:
+		return v.String(), true
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func toInt(v any) (int, bool) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		v = strings.TrimSpace(v)
+		if n, err := strconv.Atoi(v); err == nil {
+			return n, true
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return floatToInt(f)
+		}
+		return 0, false
+	case float32:
+		return floatToInt(float64(v))
+	case float64:
+		return floatToInt(v)
+	}
+	if n, ok := toInt64(v); ok && n >= math.MinInt && n <= math.MaxInt {
+		return int(n), true
+	}
+	return 0, false
+}
+
+// floatToInt truncates f to an int, failing if f is outside the range of
+// int or NaN.
+func floatToInt(f float64) (int, bool) {
+	// -math.MinInt, a power of two, is exact as a float64 unlike
+	// math.MaxInt.
+	if !(f >= math.MinInt && f < -float64(math.MinInt)) {
+		return 0, false
+	}
+	return int(f), true
+}
+
+func toBool(v any) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		return b, err == nil
+	}
+	if f, ok := toNumber(v); ok {
+		return f != 0, true
+	}
+	return false, false
+}
+
+func toFloat(v any) (float64, bool) {
+	if s, ok := v.(string); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil
+	}
+	return toNumber(v)
+}
+
+func toDuration(v any) (time.Duration, bool) {
+	switch v := v.(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		v = strings.TrimSpace(v)
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, true
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(f * float64(time.Second)), true
+		}
+		return 0, false
+	}
+	if f, ok := toNumber(v); ok {
+		return time.Duration(f * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+func toStrings(v any) ([]string, bool) {
+	switch v := v.(type) {
+	case []string:
+		return v, true
+	case []any:
+		ss := make([]string, 0, len(v))
+		for _, e := range v {
+			s, ok := toString(e)
+			if !ok {
+				return nil, false
+			}
+			ss = append(ss, s)
+		}
+		return ss, true
+	case string:
+		var ss []string
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				ss = append(ss, s)
+			}
+		}
+		return ss, true
+	}
+	return nil, false
+}
+
+// toNumber converts a value of a numeric type to a float64.
+func toNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	if n, ok := toInt64(v); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// toInt64 converts a value of an integer type to an int64.
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint:
+		return int64(n), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		if n > math.MaxInt64 {
+			return math.MaxInt64, true
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
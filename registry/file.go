@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-gost/core/logger"
+)
+
+const (
+	// DefaultReloadInterval is the interval at which a FileRegistry checks
+	// its file for changes when none is configured.
+	DefaultReloadInterval = 5 * time.Second
+)
+
+// FileOptions holds the initialization parameters for a FileRegistry.
+type FileOptions struct {
+	// ReloadInterval is the interval at which the file is checked for
+	// changes.
+	ReloadInterval time.Duration
+	// Logger is the logger for reload errors.
+	Logger logger.Logger
+}
+
+// FileOption is a functional option for configuring FileOptions.
+type FileOption func(opts *FileOptions)
+
+// ReloadIntervalFileOption sets the interval of the change check.
+func ReloadIntervalFileOption(d time.Duration) FileOption {
+	return func(opts *FileOptions) {
+		opts.ReloadInterval = d
+	}
+}
+
+// LoggerFileOption sets the logger.
+func LoggerFileOption(logger logger.Logger) FileOption {
+	return func(opts *FileOptions) {
+		opts.Logger = logger
+	}
+}
+
+// FileRegistry is a Registry whose values are decoded from a JSON file
+// holding an object of name-to-value entries. The file is checked for
+// changes every ReloadInterval: entries that were added, changed or removed
+// are registered, swapped atomically or unregistered, and reported to
+// watchers. If the file cannot be read or decoded, the values are kept and
+// the error is logged. Values may also be registered directly, in which case
+// a file entry with the same name is not registered until the name is free.
+type FileRegistry[T any] struct {
+	*registry[T]
+	path    string
+	decode  func(json.RawMessage) (T, error)
+	options FileOptions
+
+	// raw and loaded hold the entries of the last loaded file, entries the
+	// raw values of the file entries currently registered. They are only
+	// used by the reload goroutine once NewFileRegistry returns.
+	raw     map[string]json.RawMessage
+	loaded  map[string]T
+	entries map[string]json.RawMessage
+	modTime time.Time
+	size    int64
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewFileRegistry creates a FileRegistry for the file at path, whose entries
+// are decoded into values by decode. A nil decode unmarshals each entry into
+// a T with encoding/json, which suits concrete types only: for an interface
+// T such as bypass.Bypass, decode has to build the component. It returns an
+// error if the file cannot be loaded.
+func NewFileRegistry[T any](path string, decode func(json.RawMessage) (T, error), opts ...FileOption) (*FileRegistry[T], error) {
+	var options FileOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.ReloadInterval <= 0 {
+		options.ReloadInterval = DefaultReloadInterval
+	}
+	options.Logger = logger.OrDefault(options.Logger).WithFields(map[string]any{
+		"kind": "registry",
+		"file": path,
+	})
+
+	if decode == nil {
+		decode = func(data json.RawMessage) (v T, err error) {
+			err = json.Unmarshal(data, &v)
+			return
+		}
+	}
+
+	r := &FileRegistry[T]{
+		registry: newRegistry[T](),
+		path:     path,
+		decode:   decode,
+		options:  options,
+		entries:  make(map[string]json.RawMessage),
+		done:     make(chan struct{}),
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	go r.run()
+
+	return r, nil
+}
+
+// Close stops checking the file for changes.
+func (r *FileRegistry[T]) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	return nil
+}
+
+func (r *FileRegistry[T]) run() {
+	ticker := time.NewTicker(r.options.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				r.options.Logger.Error(err)
+			}
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// reload reads the file if it changed since the last load, then applies
+// its entries.
+func (r *FileRegistry[T]) reload() error {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(r.modTime) && fi.Size() == r.size {
+		r.apply()
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	values := make(map[string]T, len(raw))
+	for name, v := range raw {
+		t, err := r.decode(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		values[name] = t
+	}
+
+	r.modTime = fi.ModTime()
+	r.size = fi.Size()
+	r.raw = raw
+	r.loaded = values
+	r.apply()
+
+	return nil
+}
+
+// apply registers, swaps and unregisters values so that the registered
+// file entries match the loaded ones. Entries whose name is taken by a
+// directly registered value are retried on the next reload.
+func (r *FileRegistry[T]) apply() {
+	for name := range r.entries {
+		if _, ok := r.raw[name]; !ok {
+			r.Unregister(name)
+			delete(r.entries, name)
+		}
+	}
+	for name, v := range r.raw {
+		old, ok := r.entries[name]
+		if ok && bytes.Equal(old, v) {
+			continue
+		}
+		if ok {
+			r.Swap(name, r.loaded[name])
+			r.entries[name] = v
+			continue
+		}
+		if err := r.Register(name, r.loaded[name]); err != nil {
+			r.options.Logger.Debugf("%s: %v", name, err)
+			continue
+		}
+		r.entries[name] = v
+	}
+}
@@ -0,0 +1,225 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrDup is returned by Register when the name is already registered.
+	ErrDup = errors.New("registry: duplicate name")
+)
+
+// EventType is the kind of a registry Event.
+type EventType string
+
+const (
+	// EventRegister reports a registered value.
+	EventRegister EventType = "register"
+	// EventUnregister reports an unregistered value.
+	EventUnregister EventType = "unregister"
+	// EventUpdate reports a value swapped for another under the same name.
+	EventUpdate EventType = "update"
+)
+
+// Event is a change of a Registry. Value is the value registered, swapped
+// in or unregistered.
+type Event[T any] struct {
+	Type  EventType
+	Name  string
+	Value T
+}
+
+// Watcher is optionally implemented by a Registry to report its changes,
+// e.g. for hot reloading the components that use a named value.
+type Watcher[T any] interface {
+	// Watch returns a channel receiving the events of the Registry, in
+	// order and without loss, until ctx is done.
+	Watch(ctx context.Context) <-chan Event[T]
+}
+
+// Swapper is optionally implemented by a Registry to replace the value of a
+// name atomically, so that Get never misses it while it is replaced.
+type Swapper[T any] interface {
+	// Swap registers v under name, replacing the value registered under
+	// it if any, and returns the replaced value and whether there was one.
+	// It is reported as an EventUpdate, or an EventRegister if the name
+	// was not registered.
+	Swap(name string, v T) (old T, loaded bool)
+}
+
+type registry[T any] struct {
+	mu       sync.RWMutex
+	values   map[string]T
+	watchers map[*watcher[T]]struct{}
+}
+
+// NewRegistry creates a concurrency-safe in-memory Registry. It also
+// implements Watcher and Swapper.
+func NewRegistry[T any]() Registry[T] {
+	return newRegistry[T]()
+}
+
+func newRegistry[T any]() *registry[T] {
+	return &registry[T]{
+		values:   make(map[string]T),
+		watchers: make(map[*watcher[T]]struct{}),
+	}
+}
+
+func (r *registry[T]) Register(name string, v T) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.values[name]; ok {
+		return ErrDup
+	}
+	r.values[name] = v
+
+	r.notify(Event[T]{
+		Type:  EventRegister,
+		Name:  name,
+		Value: v,
+	})
+	return nil
+}
+
+func (r *registry[T]) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v, ok := r.values[name]
+	if !ok {
+		return
+	}
+	delete(r.values, name)
+
+	r.notify(Event[T]{
+		Type:  EventUnregister,
+		Name:  name,
+		Value: v,
+	})
+}
+
+func (r *registry[T]) Swap(name string, v T) (old T, loaded bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, loaded = r.values[name]
+	r.values[name] = v
+
+	typ := EventRegister
+	if loaded {
+		typ = EventUpdate
+	}
+	r.notify(Event[T]{
+		Type:  typ,
+		Name:  name,
+		Value: v,
+	})
+	return
+}
+
+func (r *registry[T]) IsRegistered(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.values[name]
+	return ok
+}
+
+func (r *registry[T]) Get(name string) T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.values[name]
+}
+
+func (r *registry[T]) GetAll() map[string]T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m := make(map[string]T, len(r.values))
+	for k, v := range r.values {
+		m[k] = v
+	}
+	return m
+}
+
+func (r *registry[T]) Watch(ctx context.Context) <-chan Event[T] {
+	w := &watcher[T]{
+		ch:     make(chan Event[T]),
+		signal: make(chan struct{}, 1),
+	}
+
+	r.mu.Lock()
+	r.watchers[w] = struct{}{}
+	r.mu.Unlock()
+
+	go func() {
+		w.run(ctx)
+
+		r.mu.Lock()
+		delete(r.watchers, w)
+		r.mu.Unlock()
+	}()
+
+	return w.ch
+}
+
+// notify queues ev for the watchers. It must be called with mu held.
+func (r *registry[T]) notify(ev Event[T]) {
+	for w := range r.watchers {
+		w.push(ev)
+	}
+}
+
+// watcher delivers the events of a Watch through an unbounded queue, so
+// that a slow receiver does not block the Registry.
+type watcher[T any] struct {
+	ch     chan Event[T]
+	mu     sync.Mutex
+	queue  []Event[T]
+	signal chan struct{}
+}
+
+func (w *watcher[T]) push(ev Event[T]) {
+	w.mu.Lock()
+	w.queue = append(w.queue, ev)
+	w.mu.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *watcher[T]) run(ctx context.Context) {
+	defer close(w.ch)
+
+	for {
+		select {
+		case <-w.signal:
+		case <-ctx.Done():
+			return
+		}
+
+		for {
+			w.mu.Lock()
+			if len(w.queue) == 0 {
+				w.mu.Unlock()
+				break
+			}
+			ev := w.queue[0]
+			w.queue = w.queue[1:]
+			w.mu.Unlock()
+
+			select {
+			case w.ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"context"
+	"net"
+
+	"github.com/go-gost/core/admission"
+	"github.com/go-gost/core/auth"
+	"github.com/go-gost/core/bypass"
+	"github.com/go-gost/core/resolver"
+)
+
+// The Ref functions return components that look up the value registered
+// under a name on every call and delegate to it, so that a value swapped in
+// the Registry, e.g. by a configuration reload, takes effect without
+// rebuilding the services that hold the reference. A value should be
+// swapped with Swapper.Swap, which a reference never sees missing. While
+// nothing is registered under the name, a reference behaves as documented
+// on each function.
+
+type bypassRef struct {
+	reg  Registry[bypass.Bypass]
+	name string
+}
+
+// BypassRef returns a bypass.Bypass delegating to the Bypass registered in
+// reg under name. Without one, it is a blacklist containing no address.
+func BypassRef(reg Registry[bypass.Bypass], name string) bypass.Bypass {
+	return &bypassRef{
+		reg:  reg,
+		name: name,
+	}
+}
+
+func (p *bypassRef) IsWhitelist() bool {
+	if v := p.reg.Get(p.name); v != nil {
+		return v.IsWhitelist()
+	}
+	return false
+}
+
+func (p *bypassRef) Contains(ctx context.Context, network, addr string, opts ...bypass.Option) bool {
+	if v := p.reg.Get(p.name); v != nil {
+		return v.Contains(ctx, network, addr, opts...)
+	}
+	return false
+}
+
+type resolverRef struct {
+	reg  Registry[resolver.Resolver]
+	name string
+}
+
+// ResolverRef returns a resolver.Resolver delegating to the Resolver
+// registered in reg under name. Without one, Resolve returns
+// resolver.ErrInvalid, so that callers fall back to the system resolver.
+func ResolverRef(reg Registry[resolver.Resolver], name string) resolver.Resolver {
+	return &resolverRef{
+		reg:  reg,
+		name: name,
+	}
+}
+
+func (p *resolverRef) Resolve(ctx context.Context, network, host string, opts ...resolver.Option) ([]net.IP, error) {
+	if v := p.reg.Get(p.name); v != nil {
+		return v.Resolve(ctx, network, host, opts...)
+	}
+	return nil, resolver.ErrInvalid
+}
+
+type autherRef struct {
+	reg  Registry[auth.Authenticator]
+	name string
+}
+
+// AutherRef returns an auth.Authenticator delegating to the Authenticator
+// registered in reg under name. Without one, authentication fails.
+func AutherRef(reg Registry[auth.Authenticator], name string) auth.Authenticator {
+	return &autherRef{
+		reg:  reg,
+		name: name,
+	}
+}
+
+func (p *autherRef) Authenticate(ctx context.Context, user, password string, opts ...auth.Option) (string, bool) {
+	if v := p.reg.Get(p.name); v != nil {
+		return v.Authenticate(ctx, user, password, opts...)
+	}
+	return "", false
+}
+
+type admissionRef struct {
+	reg  Registry[admission.Admission]
+	name string
+}
+
+// AdmissionRef returns an admission.Admission delegating to the Admission
+// registered in reg under name. Without one, no connection is admitted.
+func AdmissionRef(reg Registry[admission.Admission], name string) admission.Admission {
+	return &admissionRef{
+		reg:  reg,
+		name: name,
+	}
+}
+
+func (p *admissionRef) Admit(ctx context.Context, network, addr string, opts ...admission.Option) bool {
+	if v := p.reg.Get(p.name); v != nil {
+		return v.Admit(ctx, network, addr, opts...)
+	}
+	return false
+}
@@ -0,0 +1,574 @@
+package routing
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MatcherFunc adapts an ordinary function to the Matcher interface.
+type MatcherFunc func(*Request) bool
+
+// Match calls f(req).
+func (f MatcherFunc) Match(req *Request) bool {
+	return f(req)
+}
+
+// SyntaxError describes a malformed rule expression.
+type SyntaxError struct {
+	// Expr is the rule expression being compiled.
+	Expr string
+	// Pos is the byte offset in Expr where the error was detected.
+	Pos int
+	// Msg describes the error.
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("routing: %s at position %d in %q", e.Msg, e.Pos, e.Expr)
+}
+
+// Compile parses a rule expression and returns the equivalent Matcher.
+//
+// An expression combines predicate calls with && (and), || (or), ! (not) and
+// parentheses; && binds tighter than ||. Arguments are string literals,
+// either double-quoted with Go escapes or back-quoted raw strings, e.g.
+//
+//	Host("*.example.com") && Method("GET") && PathPrefix("/api") || ClientIP("10.0.0.0/8")
+//
+// The predicates, one for every Request field, are:
+//
+//	ClientIP(cidr...)          client IP is in one of the networks or equals one of the IPs
+//	Host(pattern...)           host (port stripped, case-insensitive) matches a pattern;
+//	                           "*.example.com" matches subdomains only, ".example.com"
+//	                           matches the domain and its subdomains
+//	HostRegexp(re)             host matches the regular expression
+//	Network(network...)        network equals one of the values
+//	Protocol(protocol...)      protocol equals one of the values (case-insensitive)
+//	Method(method...)          method equals one of the values (case-insensitive)
+//	Path(path...)              path equals one of the values
+//	PathPrefix(prefix...)      path starts with one of the prefixes
+//	PathRegexp(re)             path matches the regular expression
+//	Header(name[, value...])   header is present, or has one of the values
+//	HeaderRegexp(name, re)     a value of the header matches the regular expression
+//	Query(name[, value...])    query parameter is present, or has one of the values
+//	QueryRegexp(name, re)      a value of the query parameter matches the regular expression
+//	BodyRegexp(re)             the body prefix matches the regular expression
+//
+// Errors are reported as *SyntaxError.
+func Compile(expr string) (Matcher, error) {
+	p := &parser{
+		expr: expr,
+	}
+	if err := p.scan(); err != nil {
+		return nil, err
+	}
+
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t.pos, "unexpected %s", t)
+	}
+	return m, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be parsed.
+func MustCompile(expr string) Matcher {
+	m, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenIdent:
+		return fmt.Sprintf("identifier %s", t.value)
+	case tokenString:
+		return fmt.Sprintf("string %q", t.value)
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
+
+type parser struct {
+	expr   string
+	tokens []token
+	i      int
+}
+
+func (p *parser) errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{
+		Expr: p.expr,
+		Pos:  pos,
+		Msg:  fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *parser) scan() error {
+	s := p.expr
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			p.tokens = append(p.tokens, token{tokenLParen, i, "("})
+			i++
+		case c == ')':
+			p.tokens = append(p.tokens, token{tokenRParen, i, ")"})
+			i++
+		case c == ',':
+			p.tokens = append(p.tokens, token{tokenComma, i, ","})
+			i++
+		case c == '!':
+			p.tokens = append(p.tokens, token{tokenNot, i, "!"})
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			p.tokens = append(p.tokens, token{tokenAnd, i, "&&"})
+			i += 2
+		case strings.HasPrefix(s[i:], "||"):
+			p.tokens = append(p.tokens, token{tokenOr, i, "||"})
+			i += 2
+		case c == '"' || c == '`':
+			n, err := strconv.QuotedPrefix(s[i:])
+			if err != nil {
+				return p.errorf(i, "unterminated or invalid string literal")
+			}
+			v, _ := strconv.Unquote(n)
+			p.tokens = append(p.tokens, token{tokenString, i, v})
+			i += len(n)
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{tokenIdent, i, s[i:j]})
+			i = j
+		default:
+			return p.errorf(i, "unexpected character %q", c)
+		}
+	}
+	p.tokens = append(p.tokens, token{tokenEOF, len(s), ""})
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Matcher, error) {
+	m, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	ms := []Matcher{m}
+	for p.peek().kind == tokenOr {
+		p.next()
+		m, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	return MatcherFunc(func(req *Request) bool {
+		for _, m := range ms {
+			if m.Match(req) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (p *parser) parseAnd() (Matcher, error) {
+	m, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	ms := []Matcher{m}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+	if len(ms) == 1 {
+		return ms[0], nil
+	}
+	return MatcherFunc(func(req *Request) bool {
+		for _, m := range ms {
+			if !m.Match(req) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+func (p *parser) parseUnary() (Matcher, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return MatcherFunc(func(req *Request) bool {
+			return !m.Match(req)
+		}), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Matcher, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLParen:
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokenRParen {
+			return nil, p.errorf(t.pos, "expected \")\", found %s", t)
+		}
+		return m, nil
+	case tokenIdent:
+		return p.parseCall(t)
+	default:
+		return nil, p.errorf(t.pos, "expected predicate or \"(\", found %s", t)
+	}
+}
+
+func (p *parser) parseCall(name token) (Matcher, error) {
+	if t := p.next(); t.kind != tokenLParen {
+		return nil, p.errorf(t.pos, "expected \"(\" after %s, found %s", name.value, t)
+	}
+
+	var args []string
+	if p.peek().kind != tokenRParen {
+		for {
+			t := p.next()
+			if t.kind != tokenString {
+				return nil, p.errorf(t.pos, "expected string argument, found %s", t)
+			}
+			args = append(args, t.value)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if t := p.next(); t.kind != tokenRParen {
+		return nil, p.errorf(t.pos, "expected \",\" or \")\", found %s", t)
+	}
+
+	pred, ok := predicates[name.value]
+	if !ok {
+		return nil, p.errorf(name.pos, "unknown predicate %s", name.value)
+	}
+	if len(args) < pred.minArgs || (pred.maxArgs >= 0 && len(args) > pred.maxArgs) {
+		return nil, p.errorf(name.pos, "%s: %s", name.value, pred.usage)
+	}
+	m, err := pred.compile(args)
+	if err != nil {
+		return nil, p.errorf(name.pos, "%s: %v", name.value, err)
+	}
+	return m, nil
+}
+
+type predicate struct {
+	minArgs int
+	maxArgs int // -1 = unlimited
+	usage   string
+	compile func(args []string) (Matcher, error)
+}
+
+var predicates = map[string]predicate{
+	"ClientIP":     {1, -1, "expects one or more IPs or CIDRs", compileClientIP},
+	"Host":         {1, -1, "expects one or more host patterns", compileHost},
+	"HostRegexp":   {1, 1, "expects a regular expression", compileHostRegexp},
+	"Network":      {1, -1, "expects one or more networks", compileNetwork},
+	"Protocol":     {1, -1, "expects one or more protocols", compileProtocol},
+	"Method":       {1, -1, "expects one or more methods", compileMethod},
+	"Path":         {1, -1, "expects one or more paths", compilePath},
+	"PathPrefix":   {1, -1, "expects one or more path prefixes", compilePathPrefix},
+	"PathRegexp":   {1, 1, "expects a regular expression", compilePathRegexp},
+	"Header":       {1, -1, "expects a header name and optional values", compileHeader},
+	"HeaderRegexp": {2, 2, "expects a header name and a regular expression", compileHeaderRegexp},
+	"Query":        {1, -1, "expects a parameter name and optional values", compileQuery},
+	"QueryRegexp":  {2, 2, "expects a parameter name and a regular expression", compileQueryRegexp},
+	"BodyRegexp":   {1, 1, "expects a regular expression", compileBodyRegexp},
+}
+
+func compileClientIP(args []string) (Matcher, error) {
+	var nets []*net.IPNet
+	for _, arg := range args {
+		if ip := net.ParseIP(arg); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", arg)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil || req.ClientIP == nil {
+			return false
+		}
+		for _, ipNet := range nets {
+			if ipNet.Contains(req.ClientIP) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func compileHost(args []string) (Matcher, error) {
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil {
+			return false
+		}
+		for _, pattern := range args {
+			if MatchHost(pattern, req.Host) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func compileHostRegexp(args []string) (Matcher, error) {
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && re.MatchString(requestHost(req))
+	}), nil
+}
+
+// requestHost returns the lower-cased request host without port.
+func requestHost(req *Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// MatchHost reports whether host matches pattern, ignoring case and any port
+// in host. "*.example.com" matches subdomains only, ".example.com" matches
+// the domain and its subdomains, and any other pattern matches the host
+// exactly.
+func MatchHost(pattern, host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	switch {
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	case strings.HasPrefix(pattern, "."):
+		return host == pattern[1:] || strings.HasSuffix(host, pattern)
+	default:
+		return host == pattern
+	}
+}
+
+func compileNetwork(args []string) (Matcher, error) {
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && containsFold(args, req.Network, false)
+	}), nil
+}
+
+func compileProtocol(args []string) (Matcher, error) {
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && containsFold(args, req.Protocol, true)
+	}), nil
+}
+
+func compileMethod(args []string) (Matcher, error) {
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && containsFold(args, req.Method, true)
+	}), nil
+}
+
+func compilePath(args []string) (Matcher, error) {
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && containsFold(args, req.Path, false)
+	}), nil
+}
+
+func compilePathPrefix(args []string) (Matcher, error) {
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil {
+			return false
+		}
+		for _, prefix := range args {
+			if strings.HasPrefix(req.Path, prefix) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func compilePathRegexp(args []string) (Matcher, error) {
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && re.MatchString(req.Path)
+	}), nil
+}
+
+func compileHeader(args []string) (Matcher, error) {
+	name, values := args[0], args[1:]
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil || req.Header == nil {
+			return false
+		}
+		return matchValues(req.Header.Values(name), values)
+	}), nil
+}
+
+func compileHeaderRegexp(args []string) (Matcher, error) {
+	re, err := regexp.Compile(args[1])
+	if err != nil {
+		return nil, err
+	}
+	name := args[0]
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil || req.Header == nil {
+			return false
+		}
+		return matchRegexp(req.Header.Values(name), re)
+	}), nil
+}
+
+func compileQuery(args []string) (Matcher, error) {
+	name, values := args[0], args[1:]
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil || req.Query == nil {
+			return false
+		}
+		vs, ok := req.Query[name]
+		if !ok {
+			return false
+		}
+		return len(values) == 0 || matchValues(vs, values)
+	}), nil
+}
+
+func compileQueryRegexp(args []string) (Matcher, error) {
+	re, err := regexp.Compile(args[1])
+	if err != nil {
+		return nil, err
+	}
+	name := args[0]
+	return MatcherFunc(func(req *Request) bool {
+		if req == nil || req.Query == nil {
+			return false
+		}
+		return matchRegexp(req.Query[name], re)
+	}), nil
+}
+
+func compileBodyRegexp(args []string) (Matcher, error) {
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return MatcherFunc(func(req *Request) bool {
+		return req != nil && len(req.Body) > 0 && re.Match(req.Body)
+	}), nil
+}
+
+// matchValues reports whether actual is non-empty and, if expected is
+// non-empty, contains one of the expected values.
+func matchValues(actual, expected []string) bool {
+	if len(actual) == 0 {
+		return false
+	}
+	if len(expected) == 0 {
+		return true
+	}
+	for _, v := range actual {
+		if containsFold(expected, v, false) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRegexp(values []string, re *regexp.Regexp) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(ss []string, s string, fold bool) bool {
+	for _, v := range ss {
+		if v == s || (fold && strings.EqualFold(v, s)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package routing
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCompileMatch(t *testing.T) {
+	req := &Request{
+		ClientIP: net.ParseIP("10.1.2.3"),
+		Host:     "api.example.com:443",
+		Network:  "tcp",
+		Protocol: "http",
+		Method:   "GET",
+		Path:     "/api/v1/users",
+		Query:    url.Values{"id": {"42"}, "debug": {""}},
+		Header:   http.Header{"X-Token": {"abc123"}},
+		Body:     []byte(`{"user":"alice"}`),
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`ClientIP("10.0.0.0/8")`, true},
+		{`ClientIP("10.1.2.3")`, true},
+		{`ClientIP("192.168.0.0/16", "10.1.2.4")`, false},
+		{`Host("api.example.com")`, true},
+		{`Host("API.Example.COM")`, true},
+		{`Host("*.example.com")`, true},
+		{`Host("*.api.example.com")`, false},
+		{`Host(".api.example.com")`, true},
+		{`Host("example.com")`, false},
+		{`HostRegexp("^api\\.")`, true},
+		{"HostRegexp(`^www\\.`)", false},
+		{`Network("tcp")`, true},
+		{`Network("udp")`, false},
+		{`Protocol("HTTP")`, true},
+		{`Protocol("socks5")`, false},
+		{`Method("get", "post")`, true},
+		{`Method("POST")`, false},
+		{`Path("/api/v1/users")`, true},
+		{`Path("/api")`, false},
+		{`PathPrefix("/web", "/api/")`, true},
+		{`PathPrefix("/web")`, false},
+		{`PathRegexp("^/api/v[0-9]+/")`, true},
+		{`PathRegexp("^/v[0-9]+/")`, false},
+		{`Header("X-Token")`, true},
+		{`Header("x-token", "abc123")`, true},
+		{`Header("X-Token", "other")`, false},
+		{`Header("X-Missing")`, false},
+		{`HeaderRegexp("X-Token", "^abc[0-9]+$")`, true},
+		{`HeaderRegexp("X-Token", "^[0-9]+$")`, false},
+		{`Query("debug")`, true},
+		{`Query("id", "42")`, true},
+		{`Query("id", "43")`, false},
+		{`Query("missing")`, false},
+		{`QueryRegexp("id", "^[0-9]+$")`, true},
+		{`QueryRegexp("id", "^[a-z]+$")`, false},
+		{`BodyRegexp("\"user\":\"alice\"")`, true},
+		{`BodyRegexp("bob")`, false},
+
+		// && binds tighter than ||.
+		{`Method("POST") && Network("udp") || Network("tcp")`, true},
+		{`Network("tcp") || Network("udp") && Method("POST")`, true},
+		{`(Network("tcp") || Network("udp")) && Method("POST")`, false},
+		{`Method("POST") && (Network("udp") || Network("tcp"))`, false},
+
+		// ! binds tighter than && and ||.
+		{`!Method("POST") && Network("tcp")`, true},
+		{`!Method("GET") || Network("tcp")`, true},
+		{`!(Method("GET") || Network("udp"))`, false},
+		{`!!Method("GET")`, true},
+		{`!Method("GET") && Network("tcp")`, false},
+	}
+
+	for _, tt := range tests {
+		m, err := Compile(tt.expr)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", tt.expr, err)
+			continue
+		}
+		if got := m.Match(req); got != tt.want {
+			t.Errorf("Compile(%s).Match() = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestCompileNilRequest(t *testing.T) {
+	for name := range predicates {
+		expr := name + `("a", "b")`
+		if name == "ClientIP" {
+			expr = name + `("10.0.0.1")`
+		}
+		if pred := predicates[name]; pred.maxArgs == 1 {
+			expr = name + `("a")`
+		}
+		m, err := Compile(expr)
+		if err != nil {
+			t.Errorf("Compile(%s): %v", expr, err)
+			continue
+		}
+		if m.Match(nil) {
+			t.Errorf("Compile(%s).Match(nil) = true, want false", expr)
+		}
+	}
+}
+
+func TestCompileSyntaxError(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+	}{
+		{``, 0},
+		{`Method("GET") &&`, 16},
+		{`Method("GET") & Network("tcp")`, 14},
+		{`Method("GET") || || Network("tcp")`, 17},
+		{`Unknown("x")`, 0},
+		{`Method("GET") && Nope()`, 17},
+		{`Method()`, 0},
+		{`PathRegexp("a", "b")`, 0},
+		{`HeaderRegexp("X-Token")`, 0},
+		{`Method("GET"`, 12},
+		{`Method("GET",)`, 13},
+		{`Method(GET)`, 7},
+		{`Method "GET"`, 7},
+		{`(Method("GET")`, 14},
+		{`Method("GET"))`, 13},
+		{`Method("GET) && Network("tcp")`, 28},
+		{`Method("GET") Network("tcp")`, 14},
+		{`PathRegexp("(")`, 0},
+		{`ClientIP("not-an-ip")`, 0},
+		{`Method("GET") # comment`, 14},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.expr)
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Errorf("Compile(%s): error = %v, want *SyntaxError", tt.expr, err)
+			continue
+		}
+		if se.Pos != tt.pos {
+			t.Errorf("Compile(%s): position = %d, want %d (%v)", tt.expr, se.Pos, tt.pos, se)
+		}
+		if se.Expr != tt.expr {
+			t.Errorf("Compile(%s): expression = %q", tt.expr, se.Expr)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package sd
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMemoryCheckInterval is the interval at which a MemorySD expires
+	// registrations when none is configured.
+	DefaultMemoryCheckInterval = time.Second
+)
+
+// MemoryOptions holds the initialization parameters for a MemorySD.
+type MemoryOptions struct {
+	// TTL is the lifetime of registrations made without TTLOption. Zero
+	// means they never expire.
+	TTL time.Duration
+	// CheckInterval is the interval at which expired registrations are
+	// removed.
+	CheckInterval time.Duration
+}
+
+// MemoryOption is a functional option for configuring MemoryOptions.
+type MemoryOption func(opts *MemoryOptions)
+
+// TTLMemoryOption sets the default lifetime of registrations.
+func TTLMemoryOption(ttl time.Duration) MemoryOption {
+	return func(opts *MemoryOptions) {
+		opts.TTL = ttl
+	}
+}
+
+// CheckIntervalMemoryOption sets the interval of the expiry check.
+func CheckIntervalMemoryOption(d time.Duration) MemoryOption {
+	return func(opts *MemoryOptions) {
+		opts.CheckInterval = d
+	}
+}
+
+// MemorySD is an in-process SD, for tests and single-process deployments.
+// Service instances are identified by Name and ID (or Address if the ID is
+// empty). A registration with a TTL expires unless renewed in time. MemorySD
+// implements Watcher.
+type MemorySD struct {
+	options  MemoryOptions
+	mu       sync.Mutex
+	services map[string]map[string]*memoryEntry
+	watchers map[string]map[*memoryWatcher]struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+type memoryEntry struct {
+	service Service
+	ttl     time.Duration
+	expires time.Time
+}
+
+type memoryWatcher struct {
+	ch    chan Event
+	stale bool
+}
+
+// NewMemorySD creates a MemorySD.
+func NewMemorySD(opts ...MemoryOption) *MemorySD {
+	var options MemoryOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = DefaultMemoryCheckInterval
+	}
+
+	sd := &MemorySD{
+		options:  options,
+		services: make(map[string]map[string]*memoryEntry),
+		watchers: make(map[string]map[*memoryWatcher]struct{}),
+		done:     make(chan struct{}),
+	}
+	go sd.expire()
+
+	return sd
+}
+
+// Register adds or updates a service instance.
+func (sd *MemorySD) Register(ctx context.Context, service *Service, opts ...Option) error {
+	if service == nil || service.Name == "" {
+		return errors.New("sd: service name is required")
+	}
+
+	options := Options{
+		TTL: sd.options.TTL,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	entries := sd.services[service.Name]
+	if entries == nil {
+		entries = make(map[string]*memoryEntry)
+		sd.services[service.Name] = entries
+	}
+
+	id := instanceID(service)
+	entry := &memoryEntry{
+		service: *service,
+		ttl:     options.TTL,
+	}
+	if entry.ttl > 0 {
+		entry.expires = time.Now().Add(entry.ttl)
+	}
+
+	evType := EventAdd
+	if old := entries[id]; old != nil {
+		if old.service == *service {
+			evType = ""
+		} else {
+			evType = EventUpdate
+		}
+	}
+	entries[id] = entry
+
+	if evType != "" {
+		sd.notify(service.Name, Event{
+			Type:    evType,
+			Service: entry.copy(),
+		})
+	}
+	return nil
+}
+
+// Deregister removes a service instance. Removing an unknown instance is not
+// an error.
+func (sd *MemorySD) Deregister(ctx context.Context, service *Service) error {
+	if service == nil {
+		return nil
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	sd.remove(service.Name, instanceID(service))
+	return nil
+}
+
+// Renew extends the lifetime of a service instance by its TTL. It returns
+// ErrNotFound if the instance is not registered.
+func (sd *MemorySD) Renew(ctx context.Context, service *Service) error {
+	if service == nil {
+		return ErrNotFound
+	}
+
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	entry := sd.services[service.Name][instanceID(service)]
+	if entry == nil || entry.expired(time.Now()) {
+		return ErrNotFound
+	}
+	if entry.ttl > 0 {
+		entry.expires = time.Now().Add(entry.ttl)
+	}
+	return nil
+}
+
+// Get returns the live instances of the named service, ordered by ID.
+func (sd *MemorySD) Get(ctx context.Context, name string) ([]*Service, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	return sd.list(name), nil
+}
+
+// Watch implements Watcher. The first event is an EventResync with the
+// current instances. A watcher that does not keep up with the events gets
+// an EventResync in place of the ones it missed.
+func (sd *MemorySD) Watch(ctx context.Context, name string) (<-chan Event, error) {
+	w := &memoryWatcher{
+		ch: make(chan Event, 16),
+	}
+
+	sd.mu.Lock()
+	select {
+	case <-sd.done:
+		sd.mu.Unlock()
+		return nil, errors.New("sd: closed")
+	default:
+	}
+	if sd.watchers[name] == nil {
+		sd.watchers[name] = make(map[*memoryWatcher]struct{})
+	}
+	sd.watchers[name][w] = struct{}{}
+	w.ch <- Event{
+		Type:     EventResync,
+		Services: sd.list(name),
+	}
+	sd.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-sd.done:
+		}
+
+		sd.mu.Lock()
+		defer sd.mu.Unlock()
+
+		if _, ok := sd.watchers[name][w]; ok {
+			delete(sd.watchers[name], w)
+			if len(sd.watchers[name]) == 0 {
+				delete(sd.watchers, name)
+			}
+			close(w.ch)
+		}
+	}()
+
+	return w.ch, nil
+}
+
+// Close stops expiring registrations and ends all watches.
+func (sd *MemorySD) Close() error {
+	sd.once.Do(func() {
+		close(sd.done)
+	})
+	return nil
+}
+
+func (sd *MemorySD) expire() {
+	ticker := time.NewTicker(sd.options.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-sd.done:
+			return
+		}
+
+		now := time.Now()
+
+		sd.mu.Lock()
+		for name, entries := range sd.services {
+			for id, entry := range entries {
+				if entry.expired(now) {
+					sd.remove(name, id)
+				}
+			}
+		}
+		for name, watchers := range sd.watchers {
+			for w := range watchers {
+				if w.stale {
+					sd.resync(name, w)
+				}
+			}
+		}
+		sd.mu.Unlock()
+	}
+}
+
+// remove deletes an instance and notifies the watchers. It must be called
+// with mu held.
+func (sd *MemorySD) remove(name, id string) {
+	entry := sd.services[name][id]
+	if entry == nil {
+		return
+	}
+
+	delete(sd.services[name], id)
+	if len(sd.services[name]) == 0 {
+		delete(sd.services, name)
+	}
+
+	sd.notify(name, Event{
+		Type:    EventRemove,
+		Service: entry.copy(),
+	})
+}
+
+// list returns the live instances of the named service. It must be called
+// with mu held.
+func (sd *MemorySD) list(name string) []*Service {
+	now := time.Now()
+
+	var services []*Service
+	for _, entry := range sd.services[name] {
+		if !entry.expired(now) {
+			services = append(services, entry.copy())
+		}
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return instanceID(services[i]) < instanceID(services[j])
+	})
+	return services
+}
+
+// notify sends ev to the watchers of the named service. It must be called
+// with mu held.
+func (sd *MemorySD) notify(name string, ev Event) {
+	for w := range sd.watchers[name] {
+		if w.stale {
+			sd.resync(name, w)
+			continue
+		}
+
+		select {
+		case w.ch <- ev:
+		default:
+			w.stale = true
+		}
+	}
+}
+
+// resync sends the current instances to a watcher that missed events, if
+// it has room. It must be called with mu held.
+func (sd *MemorySD) resync(name string, w *memoryWatcher) {
+	select {
+	case w.ch <- Event{Type: EventResync, Services: sd.list(name)}:
+		w.stale = false
+	default:
+	}
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (e *memoryEntry) copy() *Service {
+	s := e.service
+	return &s
+}
+
+func instanceID(service *Service) string {
+	if service.ID != "" {
+		return service.ID
+	}
+	return service.Address
+}
@@ -4,14 +4,33 @@ package sd
 
 import (
 	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when renewing a service instance that is not
+	// registered, e.g. because its registration expired.
+	ErrNotFound = errors.New("sd: service not found")
 )
 
 // Options holds the initialization parameters for an SD operation.
-type Options struct{}
+type Options struct {
+	// TTL is the lifetime of the registration. The registration expires
+	// unless it is renewed within TTL. Zero uses the registry's default.
+	TTL time.Duration
+}
 
 // Option is a functional option for configuring Options.
 type Option func(opts *Options)
 
+// TTLOption sets the lifetime of a registration.
+func TTLOption(ttl time.Duration) Option {
+	return func(opts *Options) {
+		opts.TTL = ttl
+	}
+}
+
 // Service represents a discovered service instance.
 type Service struct {
 	// ID is the unique instance identifier.
@@ -40,3 +59,37 @@ type SD interface {
 	// Get returns all instances of a named service.
 	Get(ctx context.Context, name string) ([]*Service, error)
 }
+
+// EventType is the kind of a service discovery Event.
+type EventType string
+
+const (
+	// EventAdd reports a new service instance.
+	EventAdd EventType = "add"
+	// EventUpdate reports a changed service instance.
+	EventUpdate EventType = "update"
+	// EventRemove reports a deregistered or expired service instance.
+	EventRemove EventType = "remove"
+	// EventResync reports the complete set of service instances. It is sent
+	// first on each watch and whenever the watcher may have missed events,
+	// e.g. after reconnecting to the registry.
+	EventResync EventType = "resync"
+)
+
+// Event is a change of the instances of a watched service.
+type Event struct {
+	Type EventType
+	// Service is the instance added, updated or removed.
+	Service *Service
+	// Services holds all the instances for EventResync.
+	Services []*Service
+}
+
+// Watcher is optionally implemented by an SD to stream the changes of a
+// service instead of being polled with Get.
+type Watcher interface {
+	// Watch returns a channel receiving the events of the named service. The
+	// channel is closed when ctx is done or the watch ends; the caller may
+	// then watch again.
+	Watch(ctx context.Context, name string) (<-chan Event, error)
+}
@@ -0,0 +1,92 @@
+package selector
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultMaxFails is the failure count at which FailFilter starts
+	// excluding an item when no positive threshold is given.
+	DefaultMaxFails = 1
+	// DefaultFailTimeout is the initial exclusion period used by FailFilter
+	// when no positive timeout is given.
+	DefaultFailTimeout = 10 * time.Second
+	// DefaultMaxFailTimeout caps the exclusion period of FailFilter when no
+	// positive cap is given.
+	DefaultMaxFailTimeout = 5 * time.Minute
+)
+
+type failFilter[T Markable] struct {
+	maxFails       int64
+	failTimeout    time.Duration
+	maxFailTimeout time.Duration
+}
+
+// FailFilter returns a Filter that acts as a circuit breaker on the items'
+// Markers. An item whose failure count has reached maxFails is excluded
+// until failTimeout has elapsed since its last failure. Each failure beyond
+// maxFails doubles the exclusion period, up to maxFailTimeout. Once the
+// period has elapsed the item is offered again; a success resets its marker,
+// while another failure excludes it for the next, longer period.
+//
+// Items whose Marker implements Ejectable are also excluded while ejected.
+// Items without a Marker are never excluded.
+func FailFilter[T Markable](maxFails int, failTimeout, maxFailTimeout time.Duration) Filter[T] {
+	if maxFails <= 0 {
+		maxFails = DefaultMaxFails
+	}
+	if failTimeout <= 0 {
+		failTimeout = DefaultFailTimeout
+	}
+	if maxFailTimeout <= 0 {
+		maxFailTimeout = DefaultMaxFailTimeout
+	}
+	if maxFailTimeout < failTimeout {
+		maxFailTimeout = failTimeout
+	}
+
+	return &failFilter[T]{
+		maxFails:       int64(maxFails),
+		failTimeout:    failTimeout,
+		maxFailTimeout: maxFailTimeout,
+	}
+}
+
+func (f *failFilter[T]) Filter(ctx context.Context, vs ...T) []T {
+	var l []T
+	for _, v := range vs {
+		if f.available(v.Marker()) {
+			l = append(l, v)
+		}
+	}
+	return l
+}
+
+func (f *failFilter[T]) available(marker Marker) bool {
+	if marker == nil {
+		return true
+	}
+
+	if e, ok := marker.(Ejectable); ok && time.Now().Before(e.EjectedUntil()) {
+		return false
+	}
+
+	count := marker.Count()
+	if count < f.maxFails {
+		return true
+	}
+	return time.Since(marker.Time()) >= f.timeout(count)
+}
+
+// timeout returns the exclusion period for an item with count failures.
+func (f *failFilter[T]) timeout(count int64) time.Duration {
+	timeout := f.failTimeout
+	for n := count - f.maxFails; n > 0 && timeout < f.maxFailTimeout; n-- {
+		timeout *= 2
+	}
+	if timeout > f.maxFailTimeout {
+		timeout = f.maxFailTimeout
+	}
+	return timeout
+}
@@ -13,6 +13,35 @@ type Selector[T any] interface {
 	Select(context.Context, ...T) T
 }
 
+type defaultSelector[T any] struct {
+	strategy Strategy[T]
+	filters  []Filter[T]
+}
+
+// NewSelector creates a Selector that narrows the items with filters, in
+// order, and then picks one of the remaining items with strategy.
+func NewSelector[T any](strategy Strategy[T], filters ...Filter[T]) Selector[T] {
+	return &defaultSelector[T]{
+		strategy: strategy,
+		filters:  filters,
+	}
+}
+
+func (s *defaultSelector[T]) Select(ctx context.Context, vs ...T) (v T) {
+	for _, filter := range s.filters {
+		if filter != nil {
+			vs = filter.Filter(ctx, vs...)
+		}
+	}
+	if len(vs) == 0 {
+		return
+	}
+	if s.strategy == nil {
+		return vs[0]
+	}
+	return s.strategy.Apply(ctx, vs...)
+}
+
 // Strategy applies a selection algorithm (e.g. round-robin, random,
 // weighted) to choose one item from the list.
 type Strategy[T any] interface {
@@ -45,6 +74,16 @@ type Marker interface {
 	Reset()
 }
 
+// Ejectable is implemented by Markers that can eject their item from
+// selection for a period of time, such as the markers of an outlier
+// detector. Filters treat an ejected item as unavailable regardless of its
+// failure count.
+type Ejectable interface {
+	// EjectedUntil returns the time the current ejection ends. A zero or
+	// past time means the item is not ejected.
+	EjectedUntil() time.Time
+}
+
 // failMarker is the default Marker implementation using atomic operations
 // for thread safety. It tracks the time and count of the most recent failure.
 type failMarker struct {
@@ -0,0 +1,147 @@
+package selector
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/go-gost/core/metadata"
+)
+
+const (
+	// MDKeyWeight is the metadata key holding an item's selection weight,
+	// used by WeightedStrategy.
+	MDKeyWeight = "weight"
+)
+
+type roundRobinStrategy[T any] struct {
+	counter uint64
+}
+
+// RoundRobinStrategy returns a Strategy that selects items in turn.
+func RoundRobinStrategy[T any]() Strategy[T] {
+	return &roundRobinStrategy[T]{}
+}
+
+func (s *roundRobinStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	n := atomic.AddUint64(&s.counter, 1) - 1
+	return vs[n%uint64(len(vs))]
+}
+
+type randomStrategy[T any] struct{}
+
+// RandomStrategy returns a Strategy that selects an item uniformly at random.
+func RandomStrategy[T any]() Strategy[T] {
+	return &randomStrategy[T]{}
+}
+
+func (s *randomStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	return vs[rand.IntN(len(vs))]
+}
+
+type weightedStrategy[T any] struct{}
+
+// WeightedStrategy returns a Strategy that selects an item at random with a
+// probability proportional to its weight. The weight is read from the
+// MDKeyWeight metadata of items implementing metadata.Metadatable; items
+// without a positive weight count as weight 1.
+func WeightedStrategy[T any]() Strategy[T] {
+	return &weightedStrategy[T]{}
+}
+
+func (s *weightedStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	weights := make([]int, len(vs))
+	total := 0
+	for i := range vs {
+		weights[i] = weightOf(vs[i])
+		total += weights[i]
+	}
+
+	n := rand.IntN(total)
+	for i, w := range weights {
+		if n < w {
+			return vs[i]
+		}
+		n -= w
+	}
+	return vs[len(vs)-1]
+}
+
+func weightOf(v any) int {
+	mi, _ := v.(metadata.Metadatable)
+	if mi == nil {
+		return 1
+	}
+	if weight := metadata.GetInt(mi.Metadata(), MDKeyWeight); weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+type hashStrategy[T any] struct{}
+
+// HashStrategy returns a Strategy that maps the hash key carried by the
+// context (see ContextWithHash) to an item using jump consistent hashing,
+// so the same key keeps selecting the same item and only a minimal share of
+// keys move when items are appended or removed from the end of the list.
+// Without a hash key it selects an item at random.
+func HashStrategy[T any]() Strategy[T] {
+	return &hashStrategy[T]{}
+}
+
+func (s *hashStrategy[T]) Apply(ctx context.Context, vs ...T) (v T) {
+	if len(vs) == 0 {
+		return
+	}
+
+	key := HashFromContext(ctx)
+	if key == "" {
+		return vs[rand.IntN(len(vs))]
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return vs[jumpHash(h.Sum64(), len(vs))]
+}
+
+// jumpHash implements the jump consistent hash algorithm by Lamping and
+// Veach, mapping key to a bucket in [0, n).
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+type hashKey struct{}
+
+// ContextWithHash returns a context carrying key as the hash key used by
+// HashStrategy.
+func ContextWithHash(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+// HashFromContext returns the hash key carried by ctx, or an empty string.
+func HashFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	v, _ := ctx.Value(hashKey{}).(string)
+	return v
+}